@@ -1,77 +1,45 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"os"
-	"strings"
 
 	"github.com/veandco/go-sdl2/sdl"
+	"github.com/vkngwrapper/quake/filesystem"
 )
 
 type ConfigFile struct {
-	configFile *BoundedReader
+	configFile *filesystem.BoundedReader
 }
 
 var Config = &ConfigFile{}
 
+// ReadCVars sets the listed cvars from the config file without executing it, so that they can be
+// used before the rest of the engine is initialized.  Commands are split and tokenized the same way
+// the command executor would, and later assignments override earlier ones.
 func (f *ConfigFile) ReadCVars(vars []string) error {
 	if f.configFile == nil || len(vars) < 1 {
 		return nil
 	}
 
-	scanner := bufio.NewScanner(Config.configFile)
-	scanner.Split(bufio.ScanLines)
-
-	var foundVars int
-
-	for scanner.Scan() {
-		buff := scanner.Text()
-		buff = strings.ReplaceAll(buff, "\t", " ")
-
-		// Find first space
-		firstSpaceIndex := strings.Index(buff, " ")
-		if firstSpaceIndex < 1 {
-			continue
-		}
-
-		varName := buff[:firstSpaceIndex]
-		var foundVarName bool
-		for _, varStr := range vars {
-			if varName == varStr {
-				foundVarName = true
-				break
-			}
-		}
-
-		if !foundVarName {
-			continue
-		}
-
-		if buff[len(buff)-1] != '"' {
-			// Line must end with quotation mark
-			continue
-		}
-
-		// Value is first quote until the quote the value ends with
-		firstQuoteIndex := strings.Index(buff, "\"")
-		if firstQuoteIndex == len(buff)-1 {
-			// there are supposed to be two quotes
-			continue
-		}
-
-		quotedVal := buff[firstQuoteIndex : len(buff)-1]
+	contents, err := io.ReadAll(f.configFile)
+	if err != nil {
+		return err
+	}
 
-		CVars.Set(varName, quotedVal)
-		foundVars++
+	wanted := make(map[string]bool, len(vars))
+	for _, varName := range vars {
+		wanted[varName] = true
+	}
 
-		if foundVars == len(vars) {
-			break
+	for _, assignment := range CVars.ParseAssignments(contents) {
+		if wanted[assignment.Name] {
+			CVars.Set(assignment.Name, assignment.Value)
 		}
 	}
 
-	_, err := Config.configFile.Seek(0, io.SeekStart)
+	_, err = f.configFile.Seek(0, io.SeekStart)
 	return err
 }
 
@@ -83,8 +51,8 @@ func (f *ConfigFile) ReadCVarOverrides(vars []string) {
 	for _, varName := range vars {
 		index := CmdLine.CheckParam("+" + varName)
 		if index > 0 && index < CmdLine.ArgCount()-1 {
-			argVal := CmdLine.Arg(index)
-			if argVal[0] != '-' && argVal[0] != '+' {
+			argVal := CmdLine.Arg(index + 1)
+			if argVal != "" && argVal[0] != '-' && argVal[0] != '+' {
 				CVars.Set(varName, argVal)
 			}
 		}
@@ -113,7 +81,7 @@ func (f *ConfigFile) OpenConfig(configName string) bool {
 			file, err := os.Open(path)
 
 			if err == nil && file != nil {
-				reader := BoundedReaderFromOSFile(file, length)
+				reader := filesystem.BoundedReaderFromOSFile(file, length)
 				f.configFile = &reader
 				return true
 			}
@@ -0,0 +1,572 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/vkngwrapper/quake/parse"
+)
+
+type Source int
+
+const (
+	SourceClient Source = iota
+	SourceCommand
+	SourceServer
+)
+
+const MaxArgs int = 80
+const AliasMaxNameLength int = 32
+
+type CallbackFunc func()
+
+type Function struct {
+	Name     string
+	Source   Source
+	Dynamic  bool
+	Function CallbackFunc
+	Next     *Function
+}
+
+type Alias struct {
+	Name  string
+	Value string
+	Next  *Alias
+}
+
+// Host is the part of the engine that the executor depends on
+type Host interface {
+	// Initialized reports whether engine startup has finished.  Commands added afterwards
+	// are dynamic and can be removed.
+	Initialized() bool
+	// WarnCommands reports whether unknown commands and missing scripts should be reported
+	WarnCommands() bool
+	// CommandLine returns the command line that stuffcmds executes + commands from
+	CommandLine() string
+	// LoadScript reads a script file for exec
+	LoadScript(fileName string) ([]byte, bool)
+}
+
+// Variables is the cvar system.  Commands that don't match a command or alias are handed to
+// it, and command names can't be shared with variables.
+type Variables interface {
+	Exists(name string) bool
+	HandleCommand(args []string) bool
+	// Names returns the names of all variables beginning with prefix, in alphabetical order
+	Names(prefix string) []string
+	String(name string) string
+}
+
+type Executor struct {
+	out       io.Writer
+	host      Host
+	variables Variables
+
+	functions *Function
+	aliases   *Alias
+	waiting   bool
+	buffer    []rune
+
+	source    Source
+	argString string
+	args      []string
+}
+
+// New creates an executor that prints to out
+func New(out io.Writer, host Host) *Executor {
+	return &Executor{
+		out:    out,
+		host:   host,
+		buffer: make([]rune, 0, 130000),
+	}
+}
+
+// SetVariables connects the cvar system to the executor
+func (e *Executor) SetVariables(variables Variables) {
+	e.variables = variables
+}
+
+func (e *Executor) Add(name string, function CallbackFunc, source Source) *Function {
+	if e.variables != nil && e.variables.Exists(name) {
+		log.Printf("Cmd_AddCommand: %s already defined as a var\n", name)
+		return nil
+	}
+
+	// Check if function already exists
+	for cmd := e.functions; cmd != nil; cmd = cmd.Next {
+		if cmd.Name == name && cmd.Source == source {
+			if function != nil {
+				log.Printf("Cmd_AddCommand: %s already defined\n", name)
+			}
+			return nil
+		}
+	}
+
+	newCmd := &Function{
+		Name:     name,
+		Dynamic:  e.host.Initialized(),
+		Function: function,
+		Source:   source,
+	}
+
+	if e.functions == nil || strings.Compare(newCmd.Name, e.functions.Name) < 0 {
+		newCmd.Next = e.functions
+		e.functions = newCmd
+	} else {
+		prev := e.functions
+		cursor := e.functions.Next
+
+		for cursor != nil && strings.Compare(newCmd.Name, cursor.Name) > 0 {
+			prev = cursor
+			cursor = cursor.Next
+		}
+		newCmd.Next = prev.Next
+		prev.Next = newCmd
+	}
+
+	if newCmd.Dynamic {
+		return newCmd
+	}
+
+	return nil
+}
+
+func (e *Executor) Remove(cmd *Function) {
+	for link := &e.functions; *link != nil; link = &(*link).Next {
+		if *link == cmd {
+			*link = cmd.Next
+			return
+		}
+	}
+
+	log.Fatalf("Cmd_RemoveCommand: unable to remove command %s\n", cmd.Name)
+}
+
+func (e *Executor) Exists(cmdName string) bool {
+	for cmd := e.functions; cmd != nil; cmd = cmd.Next {
+		if cmd.Name == cmdName && cmd.Source == SourceCommand {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Executor) CompleteCommandName(partial string) string {
+	if partial == "" {
+		return ""
+	}
+
+	for cmd := e.functions; cmd != nil; cmd = cmd.Next {
+		if strings.HasPrefix(cmd.Name, partial) {
+			return cmd.Name
+		}
+	}
+
+	return ""
+}
+
+func (e *Executor) CmdWait() {
+	e.waiting = true
+}
+
+func (e *Executor) Waited() {
+	e.waiting = false
+}
+
+func (e *Executor) Init() {
+	e.Add("cmdlist", e.CmdList, SourceCommand)
+	e.Add("unalias", e.CmdUnalias, SourceCommand)
+	e.Add("unaliasall", e.CmdUnaliasAll, SourceCommand)
+
+	e.Add("stuffcmd", e.CmdStuffCmds, SourceCommand)
+	e.Add("exec", e.CmdExec, SourceCommand)
+	e.Add("echo", e.CmdEcho, SourceCommand)
+	e.Add("alias", e.CmdAlias, SourceCommand)
+	// TODO: Networking
+	//e.Add("cmd", e.CmdForwardToServer, SourceCommand)
+	e.Add("wait", e.CmdWait, SourceCommand)
+
+	e.Add("apropos", e.CmdApropos, SourceCommand)
+	e.Add("find", e.CmdApropos, SourceCommand)
+}
+
+func (e *Executor) AddText(text string) {
+	for _, r := range text {
+		e.buffer = append(e.buffer, r)
+	}
+}
+
+func (e *Executor) InsertText(text string) {
+	// Expand slice to cover new text size
+	addedLen := len(text) + 1
+	existingLen := len(e.buffer)
+	if cap(e.buffer) > existingLen+addedLen {
+		e.buffer = e.buffer[:existingLen+addedLen]
+	} else {
+		for i := 0; i < addedLen; i++ {
+			e.buffer = append(e.buffer, '0')
+		}
+	}
+
+	// Copy existing text to new position
+	copy(e.buffer[addedLen:addedLen+existingLen], e.buffer[:existingLen])
+
+	// Copy new text to beginning
+	for index, r := range text {
+		e.buffer[index] = r
+	}
+	e.buffer[addedLen-1] = '\n'
+}
+
+func (e *Executor) Execute() {
+	for len(e.buffer) > 0 && !e.waiting {
+		// Find a \n or ; line break
+		textIndex := parse.CommandEnd(e.buffer)
+
+		line := string(e.buffer[:textIndex])
+
+		// Delete line from buffer
+		textIndex++
+		remainingBuffer := len(e.buffer) - textIndex
+		if remainingBuffer > 0 {
+			copy(e.buffer[:remainingBuffer], e.buffer[textIndex:textIndex+remainingBuffer])
+		} else if remainingBuffer < 0 {
+			remainingBuffer = 0
+		}
+		e.buffer = e.buffer[:remainingBuffer]
+
+		e.ExecuteString(line, SourceCommand)
+	}
+}
+
+func (e *Executor) Args() string {
+	return e.argString
+}
+
+func (e *Executor) ArgCount() int {
+	return len(e.args)
+}
+
+func (e *Executor) Arg(index int) string {
+	if index < 0 || index >= len(e.args) {
+		return ""
+	}
+	return e.args[index]
+}
+
+func (e *Executor) checkParam(param string) int {
+	if param == "" {
+		log.Fatalln("Cmd_CheckParm: empty input")
+	}
+
+	for i := 1; i < len(e.args); i++ {
+		if strings.EqualFold(param, e.args[i]) {
+			return i
+		}
+	}
+
+	return 0
+}
+
+func (e *Executor) TokenizeBuffer(buffer []rune) {
+	e.args, e.argString = parse.Command(buffer, MaxArgs)
+}
+
+func (e *Executor) ExecuteString(line string, source Source) bool {
+	e.source = source
+	e.TokenizeBuffer([]rune(line))
+
+	if len(e.args) == 0 {
+		return true
+	}
+
+	for cmd := e.functions; cmd != nil; cmd = cmd.Next {
+		if cmd.Name == e.args[0] {
+			if source == SourceClient && cmd.Source != SourceClient {
+				// TODO: Report client
+				// log.Printf("%s tried to %s\n", )
+				return false
+			} else if source == SourceCommand && cmd.Source == SourceServer {
+				continue
+			} else if source == SourceServer && cmd.Source != SourceServer {
+				continue
+			}
+
+			cmd.Function()
+			return true
+		}
+	}
+
+	if source == SourceClient {
+		// TODO: Report client
+		return false
+	}
+
+	if source != SourceCommand {
+		return false
+	}
+
+	for a := e.aliases; a != nil; a = a.Next {
+		if a.Name == e.args[0] {
+			e.InsertText(a.Value)
+			return true
+		}
+	}
+
+	if e.variables == nil || !e.variables.HandleCommand(e.args) {
+		if e.host.WarnCommands() { // TODO: developer.value
+			fmt.Fprintf(e.out, "Unknown command: \"%s\"\n", e.args[0])
+		}
+	}
+
+	return true
+}
+
+func (e *Executor) TintSubstring(value string, substr string) string {
+	tintedRunes := []rune(substr)
+	for runeIndex, r := range tintedRunes {
+		tintedRunes[runeIndex] = r | 0x80
+	}
+	tintedSubstr := string(tintedRunes)
+
+	splits := strings.Split(value, substr)
+	var output strings.Builder
+
+	if len(splits) > 0 {
+		output.WriteString(splits[0])
+	}
+
+	for i := 1; i < len(splits); i++ {
+		output.WriteString(tintedSubstr)
+		output.WriteString(splits[i])
+	}
+
+	return output.String()
+}
+
+func (e *Executor) CmdList() {
+	var partial string
+
+	if len(e.args) > 1 {
+		partial = e.args[1]
+	} else {
+		partial = ""
+	}
+
+	var count int
+	for cmd := e.functions; cmd != nil; cmd = cmd.Next {
+		if partial != "" && !strings.HasPrefix(cmd.Name, partial) {
+			continue
+		}
+
+		fmt.Fprintf(e.out, "   %s\n", cmd.Name)
+		count++
+	}
+
+	fmt.Fprintf(e.out, "%d commands", count)
+	if partial != "" {
+		fmt.Fprintf(e.out, " beginning with \"%s\"", partial)
+	}
+	fmt.Fprintln(e.out)
+}
+
+func (e *Executor) CmdUnalias() {
+	switch len(e.args) {
+	default:
+		fmt.Fprintln(e.out, "unalias <name> : delete alias")
+		break
+	case 2:
+		var prev *Alias
+		for a := e.aliases; a != nil; a = a.Next {
+			if e.args[1] == a.Name {
+				if prev != nil {
+					prev.Next = a.Next
+				} else {
+					e.aliases = a.Next
+				}
+
+				return
+			}
+
+			prev = a
+		}
+
+		fmt.Fprintf(e.out, "No alias named %s\n", e.args[1])
+		break
+	}
+}
+
+func (e *Executor) CmdUnaliasAll() {
+	e.aliases = nil
+}
+
+// WriteAliases writes alias commands that restore every current alias, sorted by name
+func (e *Executor) WriteAliases(writer io.Writer) error {
+	var aliases []*Alias
+	for a := e.aliases; a != nil; a = a.Next {
+		aliases = append(aliases, a)
+	}
+
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].Name < aliases[j].Name
+	})
+
+	for _, a := range aliases {
+		_, err := fmt.Fprintf(writer, "alias %s \"%s\"\n", a.Name, strings.TrimRight(a.Value, "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Executor) CmdExec() {
+	if len(e.args) != 2 {
+		fmt.Fprintln(e.out, "exec <filename> : execute a script file")
+		return
+	}
+
+	scriptBytes, found := e.host.LoadScript(e.args[1])
+	if !found {
+		if e.host.WarnCommands() {
+			fmt.Fprintf(e.out, "couldn't exec %s\n", e.args[1])
+		}
+		return
+	}
+	if e.host.WarnCommands() {
+		fmt.Fprintf(e.out, "execing %s\n", e.args[1])
+	}
+
+	e.InsertText(string(scriptBytes) + "\n")
+}
+
+func (e *Executor) CmdStuffCmds() {
+	var j int
+	cmdLine := e.host.CommandLine()
+	cmds := make([]rune, 2*len(cmdLine))
+	plus := false
+
+	for i := 0; i < len(cmdLine); i++ {
+		if cmdLine[i] == '+' {
+			plus = true
+			if j > 0 {
+				cmds[j-1] = ';'
+				cmds[j] = ' '
+				j++
+			}
+		} else if cmdLine[i] == '-' && (i == 0 || cmdLine[i-1] == ' ') {
+			plus = false
+		} else if plus {
+			cmds[j] = rune(cmdLine[i])
+			j++
+		}
+	}
+
+	e.InsertText(string(cmds[:j]))
+}
+
+func (e *Executor) CmdAlias() {
+	switch e.ArgCount() {
+	case 1:
+		// List all aliases
+		var i int
+		for a := e.aliases; a != nil; a, i = a.Next, i+1 {
+			fmt.Fprintf(e.out, "   %s: %s", a.Name, a.Value)
+		}
+		if i > 0 {
+			fmt.Fprintf(e.out, "%d alias command(s)\n", i)
+		} else {
+			fmt.Fprintln(e.out, "no alias commands found")
+		}
+		break
+	case 2:
+		// Output current alias string
+		for a := e.aliases; a != nil; a = a.Next {
+			if e.Arg(1) == a.Name {
+				fmt.Fprintf(e.out, "   %s: %s", a.Name, a.Value)
+			}
+		}
+
+		break
+	default:
+		// Set alias string
+		name := e.Arg(1)
+		if len(name) >= AliasMaxNameLength {
+			fmt.Fprintln(e.out, "Alias name is too long")
+			return
+		}
+
+		var newAlias *Alias
+		for newAlias = e.aliases; newAlias != nil; newAlias = newAlias.Next {
+			if newAlias.Name == name {
+				break
+			}
+		}
+
+		if newAlias == nil {
+			newAlias = &Alias{
+				Next: e.aliases,
+			}
+			e.aliases = newAlias
+		}
+
+		newAlias.Name = name
+		var value strings.Builder
+
+		for i := 2; i < e.ArgCount(); i++ {
+			value.WriteString(e.Arg(i))
+			if i != e.ArgCount()-1 {
+				value.WriteRune(' ')
+			}
+		}
+
+		value.WriteRune('\n')
+		if value.Len() >= 1024 {
+			fmt.Fprintln(e.out, "alias value too long!")
+			value.Reset()
+			value.WriteRune('\n')
+		}
+
+		newAlias.Value = value.String()
+		break
+	}
+}
+
+func (e *Executor) CmdApropos() {
+	substr := e.Arg(1)
+	var hits int
+	if substr == "" {
+		fmt.Fprintf(e.out, "%s <substring> : search through commands and cvars for the given substring\n", e.Arg(0))
+		return
+	}
+
+	for cmd := e.functions; cmd != nil; cmd = cmd.Next {
+		if strings.HasPrefix(cmd.Name, substr) && cmd.Source != SourceServer {
+			hits++
+			fmt.Fprintf(e.out, "%s\n", e.TintSubstring(cmd.Name, substr))
+		}
+	}
+
+	if e.variables != nil {
+		for _, name := range e.variables.Names(substr) {
+			hits++
+			fmt.Fprintf(e.out, "%s (current value \"%s\")\n", e.TintSubstring(name, substr), e.variables.String(name))
+		}
+	}
+
+	if hits == 0 {
+		fmt.Fprintln(e.out, "no cvars nor commands contain that substring")
+	}
+}
+
+func (e *Executor) CmdEcho() {
+	for i := 1; i < e.ArgCount(); i++ {
+		fmt.Fprintf(e.out, "%s ", e.Arg(i))
+	}
+	fmt.Fprintln(e.out)
+}
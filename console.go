@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ConCharsWriter is implemented by console sinks that are able to draw the conchars charset
+// themselves (tinted text and the special glyphs).  Text sent to any other sink is converted to
+// plain UTF-8 before it is written.
+type ConCharsWriter interface {
+	io.Writer
+	WritesConChars() bool
+}
+
+type ConsoleOutput struct {
+	sink      io.Writer
+	redirects []io.Writer
+}
+
+var Console = &ConsoleOutput{sink: &TerminalSink{Out: os.Stdout}}
+
+// SetSink replaces the writer that receives console output when no redirect is active
+func (c *ConsoleOutput) SetSink(sink io.Writer) {
+	c.sink = sink
+}
+
+// Writer returns the writer that console output is currently being sent to
+func (c *ConsoleOutput) Writer() io.Writer {
+	if len(c.redirects) > 0 {
+		return c.redirects[len(c.redirects)-1]
+	}
+
+	return c.sink
+}
+
+// BeginRedirect sends all console output to the provided writer until the matching
+// EndRedirect.  Redirects can be nested, the most recent one receives the output.
+func (c *ConsoleOutput) BeginRedirect(writer io.Writer) {
+	c.redirects = append(c.redirects, writer)
+}
+
+func (c *ConsoleOutput) EndRedirect() {
+	if len(c.redirects) == 0 {
+		return
+	}
+
+	c.redirects = c.redirects[:len(c.redirects)-1]
+}
+
+func (c *ConsoleOutput) Print(text string) {
+	writer := c.Writer()
+	if writer == nil {
+		return
+	}
+
+	conCharsWriter, isConCharsWriter := writer.(ConCharsWriter)
+	if !isConCharsWriter || !conCharsWriter.WritesConChars() {
+		text = ConCharsToText(text)
+	}
+
+	_, _ = io.WriteString(writer, text)
+}
+
+// Write prints p to the console, so that the console can be used anywhere an io.Writer is expected
+func (c *ConsoleOutput) Write(p []byte) (int, error) {
+	c.Print(string(p))
+	return len(p), nil
+}
+
+func (c *ConsoleOutput) Printf(format string, args ...any) {
+	c.Print(fmt.Sprintf(format, args...))
+}
+
+func (c *ConsoleOutput) Println(args ...any) {
+	c.Print(fmt.Sprintln(args...))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vkngwrapper/quake/cvar"
+)
+
+const (
+	ConCharsetPlain int = 0
+	ConCharsetANSI  int = 1
+	ConCharsetRaw   int = 2
+)
+
+var CVarConCharset = cvar.CVar{
+	Name:      "con_charset",
+	StringVal: "0",
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeInt,
+	Min:       0,
+	Max:       2,
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiTint  = "\x1b[33m"
+	ansiGold  = "\x1b[93m"
+)
+
+// conCharsTable maps the lower half of the conchars charset to readable runes, the upper half is
+// the same set of glyphs drawn tinted
+var conCharsTable = [128]rune{
+	' ', '■', '■', '■', '■', '•', '■', '■', '■', '\t', '\n', '■', ' ', '\r', '•', '•',
+	'[', ']', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '•', '<', '=', '>',
+	' ', '!', '"', '#', '$', '%', '&', '\'', '(', ')', '*', '+', ',', '-', '.', '/',
+	'0', '1', '2', '3', '4', '5', '6', '7', '8', '9', ':', ';', '<', '=', '>', '?',
+	'@', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O',
+	'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', '[', '\\', ']', '^', '_',
+	'`', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o',
+	'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', '{', '|', '}', '~', '←',
+}
+
+// The first row of the upper half holds the separator line and a few more boxes rather than
+// a tinted copy of the lower half's first row
+var conCharsUpperTable = [16]rune{
+	'<', '=', '>', '■', '■', '•', '■', '■', '■', '■', ' ', '■', ' ', '>', '•', '•',
+}
+
+// TerminalSink writes console output to a plain text stream such as stdout, converting the conchars
+// charset according to con_charset
+type TerminalSink struct {
+	Out io.Writer
+}
+
+func (s *TerminalSink) WritesConChars() bool {
+	return true
+}
+
+func (s *TerminalSink) Write(p []byte) (n int, err error) {
+	switch int(CVarConCharset.Value) {
+	case ConCharsetRaw:
+		_, err = s.Out.Write(conCharsToRaw(string(p)))
+	case ConCharsetANSI:
+		_, err = io.WriteString(s.Out, ConCharsToANSI(string(p)))
+	default:
+		_, err = io.WriteString(s.Out, ConCharsToText(string(p)))
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *ConsoleOutput) Init() {
+	CVars.Register(&CVarConCharset)
+}
+
+// ConCharsToText converts text in the conchars charset to readable UTF-8, dropping any tinting
+func ConCharsToText(text string) string {
+	return convertConChars(text, false)
+}
+
+// ConCharsToANSI converts text in the conchars charset to UTF-8, displaying tinted and gold
+// characters using ANSI color escapes
+func ConCharsToANSI(text string) string {
+	return convertConChars(text, true)
+}
+
+// conCharsToRaw converts text in the conchars charset back to one byte per character, passing
+// through anything outside of the charset as UTF-8
+func conCharsToRaw(text string) []byte {
+	raw := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 0xff {
+			raw = utf8.AppendRune(raw, r)
+			continue
+		}
+		raw = append(raw, byte(r))
+	}
+
+	return raw
+}
+
+func convertConChars(text string, ansi bool) string {
+	var output strings.Builder
+	currentColor := ""
+
+	for _, r := range text {
+		if r > 0xff {
+			// Not part of the charset, pass it through
+			if currentColor != "" {
+				output.WriteString(ansiReset)
+				currentColor = ""
+			}
+			output.WriteRune(r)
+			continue
+		}
+
+		glyph := r & 0x7f
+		converted := conCharsTable[glyph]
+		if r >= 0x80 && glyph < 0x10 {
+			converted = conCharsUpperTable[glyph]
+		}
+
+		if ansi {
+			color := ""
+			switch {
+			case converted == '\n':
+				// Don't carry colors across lines
+			case glyph >= 0x10 && glyph <= 0x1b:
+				color = ansiGold
+			case r >= 0x80:
+				color = ansiTint
+			}
+
+			if color != currentColor {
+				if color == "" {
+					output.WriteString(ansiReset)
+				} else {
+					output.WriteString(color)
+				}
+				currentColor = color
+			}
+		}
+
+		output.WriteRune(converted)
+	}
+
+	if currentColor != "" {
+		output.WriteString(ansiReset)
+	}
+
+	return output.String()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/vkngwrapper/quake/command"
+)
+
+const HistoryFileName = "history.txt"
+const MaxHistoryLines int = 256
+
+type ConsoleHistory struct {
+	lines    []string
+	cursor   int
+	filePath string
+	dirty    bool // lines have been added since the file was last written
+}
+
+var History = &ConsoleHistory{}
+
+func (h *ConsoleHistory) Init() {
+	Cmds.Add("history", h.CmdHistory, command.SourceCommand)
+
+	h.filePath = UserDirPath(HistoryFileName)
+	h.Load()
+}
+
+// Load replaces the current history with the contents of the history file, if there is one
+func (h *ConsoleHistory) Load() {
+	h.lines = h.lines[:0]
+	h.cursor = 0
+	h.dirty = false
+
+	file, err := os.Open(h.filePath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+
+	for scanner.Scan() {
+		h.append(scanner.Text())
+	}
+	h.cursor = len(h.lines)
+}
+
+// Save writes the current history out to the history file, if it has changed since it was last
+// loaded or saved
+func (h *ConsoleHistory) Save() error {
+	if h.filePath == "" || !h.dirty {
+		return nil
+	}
+
+	var contents strings.Builder
+	for _, line := range h.lines {
+		contents.WriteString(line)
+		contents.WriteRune('\n')
+	}
+
+	err := os.WriteFile(h.filePath, []byte(contents.String()), 0666)
+	if err != nil {
+		return err
+	}
+
+	h.dirty = false
+	return nil
+}
+
+func (h *ConsoleHistory) append(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	// Don't record the same line twice in a row
+	if len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+
+	if len(h.lines) >= MaxHistoryLines {
+		copy(h.lines, h.lines[1:])
+		h.lines = h.lines[:len(h.lines)-1]
+	}
+	h.lines = append(h.lines, line)
+}
+
+// Add records an executed console line and resets history navigation.  The history file is written
+// when the host shuts down.
+func (h *ConsoleHistory) Add(line string) {
+	h.append(line)
+	h.cursor = len(h.lines)
+	h.dirty = true
+}
+
+// Submit records a line entered into the graphical or stdin console and queues it for execution
+func (h *ConsoleHistory) Submit(line string) {
+	Console.Printf("]%s\n", line)
+	h.Add(line)
+	Cmds.AddText(line + "\n")
+}
+
+func (h *ConsoleHistory) Len() int {
+	return len(h.lines)
+}
+
+func (h *ConsoleHistory) Line(index int) string {
+	if index < 0 || index >= len(h.lines) {
+		return ""
+	}
+	return h.lines[index]
+}
+
+// Previous steps history navigation one line back and returns that line, or false if
+// the oldest line has already been reached
+func (h *ConsoleHistory) Previous() (string, bool) {
+	if h.cursor <= 0 {
+		return "", false
+	}
+
+	h.cursor--
+	return h.lines[h.cursor], true
+}
+
+// Next steps history navigation one line forward.  Stepping past the newest line returns
+// an empty line to edit.
+func (h *ConsoleHistory) Next() (string, bool) {
+	if h.cursor >= len(h.lines) {
+		return "", false
+	}
+
+	h.cursor++
+	return h.Line(h.cursor), true
+}
+
+// SearchPrefix finds the newest line before the given index that starts with prefix.  Pass
+// Len() to search the whole history.  The index of the line found is returned, or -1 if
+// there was no match.
+func (h *ConsoleHistory) SearchPrefix(prefix string, before int) (string, int) {
+	return h.search(before, func(line string) bool {
+		return strings.HasPrefix(line, prefix)
+	})
+}
+
+// SearchSubstring finds the newest line before the given index that contains substr
+func (h *ConsoleHistory) SearchSubstring(substr string, before int) (string, int) {
+	return h.search(before, func(line string) bool {
+		return strings.Contains(line, substr)
+	})
+}
+
+func (h *ConsoleHistory) search(before int, match func(line string) bool) (string, int) {
+	if before > len(h.lines) {
+		before = len(h.lines)
+	}
+
+	for i := before - 1; i >= 0; i-- {
+		if match(h.lines[i]) {
+			return h.lines[i], i
+		}
+	}
+
+	return "", -1
+}
+
+func (h *ConsoleHistory) CmdHistory() {
+	substr := Cmds.Arg(1)
+
+	var count int
+	for i, line := range h.lines {
+		if substr != "" && !strings.Contains(line, substr) {
+			continue
+		}
+
+		Console.Printf("%4d: %s\n", i+1, Cmds.TintSubstring(line, substr))
+		count++
+	}
+
+	if substr != "" && count == 0 {
+		Console.Printf("no history lines contain \"%s\"\n", substr)
+	}
+}
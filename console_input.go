@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/vkngwrapper/quake/command"
+)
+
+// ConsoleInput is the line being typed into the graphical console
+type ConsoleInput struct {
+	line []rune
+}
+
+var ConsoleLine = &ConsoleInput{}
+
+// Init makes the console line the handler for the console key layer
+func (c *ConsoleInput) Init() {
+	Keys.SetLayerHandler(KeyDestConsole, c.KeyEvent)
+
+	Cmds.Add("toggleconsole", c.CmdToggleConsole, command.SourceCommand)
+}
+
+// KeyEvent edits the line while the console has key focus.  Typed characters aren't handled
+// here, they arrive as text through InsertText.
+func (c *ConsoleInput) KeyEvent(key Key, down bool) {
+	if !down {
+		return
+	}
+
+	switch key {
+	case sdl.K_RETURN, sdl.K_KP_ENTER:
+		c.Enter()
+	case sdl.K_BACKSPACE:
+		c.Backspace()
+	case sdl.K_UP:
+		c.HistoryPrevious()
+	case sdl.K_DOWN:
+		c.HistoryNext()
+	case sdl.K_ESCAPE:
+		Keys.SetDest(KeyDestGame)
+	}
+}
+
+// Toggle opens the console, or closes it if it's open
+func (c *ConsoleInput) Toggle() {
+	if Keys.Dest() == KeyDestConsole {
+		Keys.SetDest(KeyDestGame)
+		return
+	}
+
+	Keys.SetDest(KeyDestConsole)
+}
+
+func (c *ConsoleInput) CmdToggleConsole() {
+	c.Toggle()
+}
+
+// Text returns the line typed so far
+func (c *ConsoleInput) Text() string {
+	return string(c.line)
+}
+
+// InsertText adds typed text to the end of the line
+func (c *ConsoleInput) InsertText(text string) {
+	c.line = append(c.line, []rune(text)...)
+}
+
+// Backspace removes the last character of the line
+func (c *ConsoleInput) Backspace() {
+	if len(c.line) > 0 {
+		c.line = c.line[:len(c.line)-1]
+	}
+}
+
+// Enter records the line in the history and queues it for execution, then starts a new line
+func (c *ConsoleInput) Enter() {
+	History.Submit(string(c.line))
+	c.line = c.line[:0]
+}
+
+// HistoryPrevious replaces the line with the one before it in the history
+func (c *ConsoleInput) HistoryPrevious() {
+	line, ok := History.Previous()
+	if ok {
+		c.line = []rune(line)
+	}
+}
+
+// HistoryNext replaces the line with the one after it in the history
+func (c *ConsoleInput) HistoryNext() {
+	line, ok := History.Next()
+	if ok {
+		c.line = []rune(line)
+	}
+}
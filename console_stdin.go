@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// StdinConsole reads console lines from stdin for dedicated servers
+type StdinConsole struct {
+	lines chan string
+}
+
+var DedicatedConsole = &StdinConsole{}
+
+func (c *StdinConsole) Init() {
+	c.lines = make(chan string, 32)
+	go c.read(os.Stdin)
+}
+
+func (c *StdinConsole) read(input io.Reader) {
+	scanner := bufio.NewScanner(input)
+	scanner.Split(bufio.ScanLines)
+
+	for scanner.Scan() {
+		c.lines <- scanner.Text()
+	}
+	close(c.lines)
+}
+
+// Poll submits any lines that have been entered since the last call, it should be run once
+// per host frame
+func (c *StdinConsole) Poll() {
+	if c.lines == nil {
+		return
+	}
+
+	for {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				c.lines = nil
+				return
+			}
+			History.Submit(line)
+		default:
+			return
+		}
+	}
+}
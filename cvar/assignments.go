@@ -0,0 +1,45 @@
+package cvar
+
+import (
+	"github.com/vkngwrapper/quake/command"
+	"github.com/vkngwrapper/quake/parse"
+)
+
+// Assignment is a cvar value set by a line of a config file
+type Assignment struct {
+	Name  string
+	Value string
+	Set   bool // set with set or seta, rather than by naming the cvar
+}
+
+// ParseAssignments finds every command in a config file that sets a cvar, either with set,
+// seta or by naming the cvar directly, in the order they appear.  Nothing is executed.  Lines
+// that run a command, such as bind or alias, are skipped, but other names aren't checked against
+// registered cvars.
+func (l *Library) ParseAssignments(contents []byte) []Assignment {
+	var assignments []Assignment
+
+	text := []rune(string(contents))
+	for len(text) > 0 {
+		end := parse.CommandEnd(text)
+		line := text[:end]
+		if end < len(text) {
+			end++
+		}
+		text = text[end:]
+
+		args, _ := parse.Command(line, command.MaxArgs)
+		set := len(args) > 0 && (args[0] == "set" || args[0] == "seta")
+		if set {
+			args = args[1:]
+		}
+
+		if len(args) < 2 || (!set && l.cmds.Exists(args[0])) {
+			continue
+		}
+
+		assignments = append(assignments, Assignment{Name: args[0], Value: args[1], Set: set})
+	}
+
+	return assignments
+}
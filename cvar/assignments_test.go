@@ -0,0 +1,124 @@
+package cvar
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/vkngwrapper/quake/command"
+)
+
+const testConfigCfg = `// This file is generated by vkQuake, do not modify.
+unbindall
+bind "TAB" "+showscores"
+bind "ENTER" "+jump"
+bind "ESCAPE" "togglemenu"
+bind "SPACE" "+jump"
+bind "'" "+mlook"
+bind "w" "+forward"
+bind "MOUSE1" "+attack"
+alias zoom_in "fov 90; wait; fov 70"
+_cl_color "30"
+_cl_name "Big Player"
+cl_forwardspeed "400"
+seta gl_texturemode "GL_NEAREST_MIPMAP_LINEAR"
+vid_fullscreen "0"
+vid_width "1920"
+`
+
+const testAutoexecCfg = `// autoexec.cfg
+fov 110; sensitivity 6 // look around faster
+set r_wateralpha 0.6
+alias +zoom "fov 50; sensitivity 3"
+alias -zoom "fov 110; sensitivity 6"
+bind MOUSE2 +zoom
+exec other.cfg
+/* block comments are skipped */
+seta "crosshair" "1"; echo "loaded autoexec"
+vid_width 1280
+`
+
+func TestParseAssignments(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     []Assignment
+	}{
+		{
+			name:     "config.cfg",
+			contents: testConfigCfg,
+			want: []Assignment{
+				{Name: "_cl_color", Value: "30"},
+				{Name: "_cl_name", Value: "Big Player"},
+				{Name: "cl_forwardspeed", Value: "400"},
+				{Name: "gl_texturemode", Value: "GL_NEAREST_MIPMAP_LINEAR", Set: true},
+				{Name: "vid_fullscreen", Value: "0"},
+				{Name: "vid_width", Value: "1920"},
+			},
+		},
+		{
+			name:     "autoexec.cfg",
+			contents: testAutoexecCfg,
+			want: []Assignment{
+				{Name: "fov", Value: "110"},
+				{Name: "sensitivity", Value: "6"},
+				{Name: "r_wateralpha", Value: "0.6", Set: true},
+				{Name: "crosshair", Value: "1", Set: true},
+				{Name: "vid_width", Value: "1280"},
+			},
+		},
+		{
+			name:     "quoted separators",
+			contents: "_cl_name \"a;b\" // \"c\" \"d\"\nseta hostname \"My Server\"",
+			want: []Assignment{
+				{Name: "_cl_name", Value: "a;b"},
+				{Name: "hostname", Value: "My Server", Set: true},
+			},
+		},
+		{
+			name:     "set without a value",
+			contents: "seta\nset fov\nfov\n",
+			want:     nil,
+		},
+	}
+
+	l, cmds, _, _ := newTestLibrary()
+	cmds.Init()
+	for _, name := range []string{"bind", "unbindall", "togglemenu"} {
+		cmds.Add(name, func() {}, command.SourceCommand)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := l.ParseAssignments([]byte(test.contents))
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseAssignmentsDefaultCfg(t *testing.T) {
+	// The default.cfg that ships in vkQuake.pak
+	contents, err := os.ReadFile("../Misc/vq_pak/default.cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, cmds, _, _ := newTestLibrary()
+	cmds.Init()
+	for _, name := range []string{"bind", "unbindall"} {
+		cmds.Add(name, func() {}, command.SourceCommand)
+	}
+
+	want := []Assignment{
+		{Name: "volume", Value: "0.7"},
+		{Name: "sensitivity", Value: "3"},
+		{Name: "viewsize", Value: "110"},
+		{Name: "crosshair", Value: "1"},
+	}
+	got := l.ParseAssignments(contents)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,632 @@
+package cvar
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vkngwrapper/quake/command"
+)
+
+type Flags int
+
+const (
+	FlagArchive = 1 << iota
+	FlagNotify
+	FlagServerInfo
+	FlagUserInfo
+	FlagChanged
+	FlagROM
+	FlagLocked
+	FlagRegistered
+	FlagCallback
+	FlagUserDefined
+	FlagAutoCVar
+	FlagSeta
+	FlagLatched
+)
+
+// Host is the part of the engine that the library depends on
+type Host interface {
+	// Initialized reports whether engine startup has finished.  Until then, latched cvars
+	// change immediately and every change also sets the default value.
+	Initialized() bool
+	// LoadScript reads a config file from wherever exec would find it
+	LoadScript(fileName string) ([]byte, bool)
+	// UserDirPath returns the path of a file in the user's writable directory
+	UserDirPath(fileName string) string
+}
+
+type CallbackFunc func(cvar *CVar)
+
+type CVar struct {
+	Name          string
+	StringVal     string
+	Value         float64
+	Flags         Flags
+	DefaultString string
+	Callback      CallbackFunc
+
+	// Optional validation, enforced whenever the value changes
+	Type      Type
+	Min       float64 // Range for int and float cvars, ignored if Min == Max
+	Max       float64
+	Values    []string // Allowed values for enum cvars
+	MaxLength int      // Maximum length for string cvars, 0 is unlimited
+
+	// Latched cvars hold changes until their latch group is committed
+	LatchGroup    LatchGroup
+	LatchedString string
+	latchPending  bool
+}
+
+type Library struct {
+	out  io.Writer
+	cmds *command.Executor
+	host Host
+
+	vars       map[string]*CVar
+	sortedVars []*CVar // Alphabetical by name
+
+	subscribers       map[*CVar][]*cvarSubscriber
+	globalSubscribers []*cvarSubscriber
+	batchDepth        int
+	batched           []*CVar
+}
+
+// New creates a library that prints to out and registers its commands with cmds.  The library is
+// also connected to cmds, so that commands naming a cvar reach it.
+func New(out io.Writer, cmds *command.Executor, host Host) *Library {
+	l := &Library{
+		out:  out,
+		cmds: cmds,
+		host: host,
+	}
+	cmds.SetVariables(l)
+
+	return l
+}
+
+func (l *Library) Init() {
+	l.cmds.Add("cvarlist", l.CmdList, command.SourceCommand)
+	l.cmds.Add("toggle", l.CmdToggle, command.SourceCommand)
+	l.cmds.Add("cycle", l.CmdCycle, command.SourceCommand)
+	l.cmds.Add("inc", l.CmdInc, command.SourceCommand)
+	l.cmds.Add("reset", l.CmdReset, command.SourceCommand)
+	l.cmds.Add("resetall", l.CmdResetAll, command.SourceCommand)
+	l.cmds.Add("resetcfg", l.CmdResetCfg, command.SourceCommand)
+	l.cmds.Add("set", l.CmdSet, command.SourceCommand)
+	l.cmds.Add("seta", l.CmdSet, command.SourceCommand)
+	l.cmds.Add("profile_save", l.CmdProfileSave, command.SourceCommand)
+	l.cmds.Add("profile_list", l.CmdProfileList, command.SourceCommand)
+	l.cmds.Add("profile_diff", l.CmdProfileDiff, command.SourceCommand)
+	l.cmds.Add("profile_apply", l.CmdProfileApply, command.SourceCommand)
+	l.cmds.Add("cvardiff", l.CmdDiff, command.SourceCommand)
+	l.cmds.Add("cvardiff_file", l.CmdDiffFile, command.SourceCommand)
+}
+
+func (l *Library) FindVar(name string) *CVar {
+	return l.vars[name]
+}
+
+func (l *Library) Exists(name string) bool {
+	return l.FindVar(name) != nil
+}
+
+// Names returns the names of all cvars beginning with prefix, in alphabetical order
+func (l *Library) Names(prefix string) []string {
+	var names []string
+	for index := l.sortedIndex(prefix); index < len(l.sortedVars); index++ {
+		if !strings.HasPrefix(l.sortedVars[index].Name, prefix) {
+			break
+		}
+		names = append(names, l.sortedVars[index].Name)
+	}
+
+	return names
+}
+
+// All returns every registered cvar in alphabetical order.  The slice belongs to the library
+// and must not be modified.
+func (l *Library) All() []*CVar {
+	return l.sortedVars
+}
+
+// sortedIndex returns the position in sortedVars of the first cvar whose name isn't before name
+func (l *Library) sortedIndex(name string) int {
+	return sort.Search(len(l.sortedVars), func(i int) bool {
+		return l.sortedVars[i].Name >= name
+	})
+}
+
+// FindVarAfter returns the first cvar alphabetically after prevName that has all of withFlags,
+// or the first such cvar if prevName is empty
+func (l *Library) FindVarAfter(prevName string, withFlags Flags) *CVar {
+	var index int
+	if prevName != "" {
+		if l.FindVar(prevName) == nil {
+			return nil
+		}
+		index = l.sortedIndex(prevName) + 1
+	}
+
+	for ; index < len(l.sortedVars); index++ {
+		v := l.sortedVars[index]
+		if withFlags == 0 || (v.Flags&withFlags) == withFlags {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (l *Library) Lock(varName string) {
+	v := l.FindVar(varName)
+	if v != nil {
+		v.Flags |= FlagLocked
+	}
+}
+
+func (l *Library) Unlock(varName string) {
+	v := l.FindVar(varName)
+	if v != nil {
+		v.Flags &= ^FlagLocked
+	}
+}
+
+func (l *Library) UnlockAll() {
+	for _, v := range l.sortedVars {
+		v.Flags &= ^FlagLocked
+	}
+}
+
+func (l *Library) Value(varName string) float64 {
+	v := l.FindVar(varName)
+	if v == nil {
+		return 0
+	}
+
+	return v.Value
+}
+
+func (l *Library) String(varName string) string {
+	v := l.FindVar(varName)
+	if v == nil {
+		return ""
+	}
+
+	return v.StringVal
+}
+
+func (l *Library) CompleteVariableName(partialName string) string {
+	if partialName == "" {
+		return ""
+	}
+
+	index := l.sortedIndex(partialName)
+	if index < len(l.sortedVars) && strings.HasPrefix(l.sortedVars[index].Name, partialName) {
+		return l.sortedVars[index].Name
+	}
+
+	return ""
+}
+
+func (l *Library) Reset(varName string) {
+	v := l.FindVar(varName)
+	if v == nil {
+		fmt.Fprintf(l.out, "variable \"%s\" not found\n", varName)
+	} else {
+		l.SetQuick(v, v.DefaultString)
+	}
+}
+
+func (l *Library) SetQuick(v *CVar, value string) {
+	if v.Flags&(FlagROM|FlagLocked) != 0 {
+		return
+	}
+	if v.Flags&FlagRegistered == 0 {
+		return
+	}
+
+	value, err := l.validate(v, value)
+	if err != nil {
+		fmt.Fprintf(l.out, "%s: %s\n", v.Name, err)
+		return
+	}
+
+	if l.latch(v, value) {
+		return
+	}
+
+	l.apply(v, value)
+}
+
+// apply stores an already validated value and notifies anything watching the cvar
+func (l *Library) apply(v *CVar, value string) {
+	if v.StringVal != "" && v.StringVal == value {
+		// no change
+		return
+	}
+
+	if v.StringVal != "" {
+		v.Flags |= FlagChanged
+	}
+	v.StringVal = value
+
+	numVal, err := strconv.ParseFloat(value, 64)
+	if err == nil {
+		v.Value = numVal
+	} else {
+		v.Value = 0
+	}
+
+	if v.DefaultString == "" || !l.host.Initialized() {
+		v.DefaultString = value
+	}
+
+	l.notify(v)
+	if v.Flags&FlagAutoCVar != 0 {
+		//TODO: PR_AutoCvarChanged(v)
+	}
+}
+
+func (l *Library) SetValueQuick(v *CVar, value float64) {
+	l.SetQuick(v, FormatFloat(value))
+}
+
+func (l *Library) Set(varName string, value string) {
+	v := l.FindVar(varName)
+	if v == nil {
+		fmt.Fprintf(l.out, "Cvar_Set: variable %s not found\n", varName)
+		return
+	}
+
+	l.SetQuick(v, value)
+}
+
+func (l *Library) SetValue(varName string, value float64) {
+	v := l.FindVar(varName)
+	if v == nil {
+		fmt.Fprintf(l.out, "Cvar_Set: variable %s not found\n", varName)
+		return
+	}
+
+	l.SetValueQuick(v, value)
+}
+
+func (l *Library) SetROM(varName string, value string) {
+	v := l.FindVar(varName)
+	if v != nil {
+		v.Flags &= ^FlagROM
+		l.SetQuick(v, value)
+		v.Flags |= FlagROM
+	}
+}
+
+func (l *Library) SetValueROM(varName string, value float64) {
+	v := l.FindVar(varName)
+	if v != nil {
+		v.Flags &= ^FlagROM
+		l.SetValueQuick(v, value)
+		v.Flags |= FlagROM
+	}
+}
+
+func (l *Library) Register(variable *CVar) {
+	existingVar := l.FindVar(variable.Name)
+	if existingVar != nil {
+		log.Printf("Can't register variable %s, already defined\n", variable.Name)
+		return
+	}
+
+	if l.cmds.Exists(variable.Name) {
+		log.Printf("Cvar_RegisterVariable: %s is a command\n", variable.Name)
+		return
+	}
+
+	if l.vars == nil {
+		l.vars = make(map[string]*CVar)
+	}
+	l.vars[variable.Name] = variable
+
+	index := l.sortedIndex(variable.Name)
+	l.sortedVars = slices.Insert(l.sortedVars, index, variable)
+
+	value := variable.StringVal
+	variable.Flags |= FlagRegistered
+	variable.Value = 0
+	variable.StringVal = ""
+	variable.DefaultString = ""
+
+	if variable.Flags&FlagCallback == 0 {
+		variable.Callback = nil
+	}
+
+	// The initial value always applies immediately
+	heldFlags := variable.Flags & (FlagROM | FlagLatched)
+	variable.Flags &= ^(FlagROM | FlagLatched)
+	l.SetQuick(variable, value)
+	variable.Flags |= heldFlags
+}
+
+func (l *Library) Create(varName string, value string) *CVar {
+	v := l.FindVar(varName)
+
+	if v != nil {
+		return v
+	}
+	if l.cmds.Exists(varName) {
+		return nil
+	}
+
+	v = &CVar{
+		Name:      varName,
+		Flags:     FlagUserDefined,
+		StringVal: value,
+	}
+	l.Register(v)
+	return v
+}
+
+func (l *Library) SetCallback(v *CVar, callback CallbackFunc) {
+	v.Callback = callback
+	if v.Callback != nil {
+		v.Flags |= FlagCallback
+	} else {
+		v.Flags &= ^FlagCallback
+	}
+}
+
+// HandleCommand handles a command naming a cvar, printing its value or setting a new one.  It
+// returns false if there's no such cvar.
+func (l *Library) HandleCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	v := l.FindVar(args[0])
+	if v == nil {
+		return false
+	}
+
+	if len(args) == 1 {
+		pending, isPending := v.Pending()
+		if isPending {
+			fmt.Fprintf(l.out, "\"%s\" is \"%s\" (pending \"%s\")\n", v.Name, v.StringVal, pending)
+		} else {
+			fmt.Fprintf(l.out, "\"%s\" is \"%s\"\n", v.Name, v.StringVal)
+		}
+		return true
+	}
+
+	l.Set(args[0], args[1])
+	return true
+}
+
+// WriteVariables writes commands that restore every archived cvar.  Latched cvars are written
+// with their pending value, if they have one, so the change takes effect on the next launch.
+func (l *Library) WriteVariables(writer io.Writer) error {
+	var err error
+	for _, v := range l.sortedVars {
+		if v.Flags&FlagArchive == 0 {
+			continue
+		}
+
+		if v.Flags&(FlagUserDefined|FlagSeta) != 0 {
+			_, err = fmt.Fprint(writer, "seta ")
+			if err != nil {
+				return err
+			}
+		}
+
+		value := v.StringVal
+		pending, isPending := v.Pending()
+		if isPending {
+			value = pending
+		}
+
+		_, err = fmt.Fprintf(writer, "%s \"%s\"\n", v.Name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *Library) CmdList() {
+	var partial string
+
+	if l.cmds.ArgCount() > 1 {
+		partial = l.cmds.Arg(1)
+	}
+
+	var count int
+	for _, cvar := range l.sortedVars {
+		if partial != "" && !strings.HasPrefix(cvar.Name, partial) {
+			continue
+		}
+
+		archiveIndicator := " "
+		notifyIndicator := " "
+		if cvar.Flags&FlagArchive != 0 {
+			archiveIndicator = "*"
+		}
+		if cvar.Flags&FlagNotify != 0 {
+			notifyIndicator = "s"
+		}
+
+		constraint := cvar.Constraint()
+		if constraint != "" {
+			constraint = " " + constraint
+		}
+
+		pending, isPending := cvar.Pending()
+		if isPending {
+			pending = fmt.Sprintf(" (pending \"%s\")", pending)
+		}
+
+		fmt.Fprintf(l.out, "%s%s %s \"%s\"%s%s\n", archiveIndicator, notifyIndicator, cvar.Name, cvar.StringVal, pending, constraint)
+		count++
+	}
+
+	fmt.Fprintf(l.out, "%d cvars", count)
+	if partial != "" {
+		fmt.Fprintf(l.out, " beginning with \"%s\"", partial)
+	}
+	fmt.Fprintln(l.out)
+}
+
+func (l *Library) CmdToggle() {
+	if l.cmds.ArgCount() < 2 {
+		fmt.Fprintf(l.out, "toggle <cvar> [value] [altvalue]: toggle cvar\n")
+		return
+	}
+
+	cvar := l.FindVar(l.cmds.Arg(1))
+	if cvar == nil {
+		fmt.Fprintf(l.out, "variable \"%s\" not found\n", l.cmds.Arg(1))
+		return
+	}
+
+	if l.cmds.ArgCount() >= 3 {
+		newVal := l.cmds.Arg(2)
+		defaultVal := cvar.DefaultString
+
+		if l.cmds.ArgCount() > 3 {
+			defaultVal = l.cmds.Arg(3)
+		}
+
+		if cvar.StringVal == newVal {
+			l.SetQuick(cvar, defaultVal)
+		} else {
+			l.SetQuick(cvar, newVal)
+		}
+		return
+	}
+
+	if cvar.Value == 0 {
+		l.SetQuick(cvar, "1")
+	} else {
+		l.SetQuick(cvar, "0")
+	}
+}
+
+func (l *Library) CmdInc() {
+	varName := l.cmds.Arg(1)
+
+	switch l.cmds.ArgCount() {
+	default:
+		fallthrough
+	case 1:
+		fmt.Fprintln(l.out, "inc <cvar> [amount] : increment cvar")
+		break
+	case 2:
+		l.SetValue(varName, l.Value(varName)+1)
+		break
+	case 3:
+		val, err := strconv.ParseFloat(l.cmds.Arg(2), 64)
+		if err != nil {
+			val = 1
+		}
+		l.SetValue(varName, l.Value(varName)+val)
+		break
+	}
+}
+
+func (l *Library) CmdCycle() {
+	if l.cmds.ArgCount() < 3 {
+		fmt.Fprintln(l.out, "cycle <cvar> <value list>: cycle cvar through a list of values")
+		return
+	}
+
+	cvar := l.FindVar(l.cmds.Arg(1))
+	if cvar == nil {
+		fmt.Fprintf(l.out, "variable \"%s\" not found\n", l.cmds.Arg(1))
+		return
+	}
+
+	// loop through the args until you find one that matches the current cvar value.
+	// yes, this will get stuck on a list that contains the same value twice
+	matchIndex := l.cmds.ArgCount() - 1
+	for i := 2; i < l.cmds.ArgCount()-1; i++ {
+		argValue := l.cmds.Arg(i)
+		argFloatValue, err := strconv.ParseFloat(argValue, 64)
+		if err != nil && argValue == cvar.StringVal {
+			// This is a string
+			matchIndex = i
+			break
+		} else if err == nil && math.Abs(argFloatValue-cvar.Value) < 0.0001 {
+			// This is a float
+			matchIndex = i
+			break
+		}
+	}
+
+	matchIndex++
+	if matchIndex == l.cmds.ArgCount() {
+		matchIndex = 2
+	}
+
+	l.Set(cvar.Name, l.cmds.Arg(matchIndex))
+}
+
+func (l *Library) CmdReset() {
+	switch l.cmds.ArgCount() {
+	default:
+		fallthrough
+	case 1:
+		fmt.Fprintln(l.out, "reset <cvar> : reset cvar to default")
+		break
+	case 2:
+		l.Reset(l.cmds.Arg(1))
+		break
+	}
+}
+
+func (l *Library) CmdResetAll() {
+	for _, cvar := range l.sortedVars {
+		l.Reset(cvar.Name)
+	}
+}
+
+func (l *Library) CmdResetCfg() {
+	for _, cvar := range l.sortedVars {
+		if cvar.Flags&FlagArchive != 0 {
+			l.Reset(cvar.Name)
+		}
+	}
+}
+
+func (l *Library) CmdSet() {
+	varName := l.cmds.Arg(1)
+	varValue := l.cmds.Arg(2)
+
+	if l.cmds.ArgCount() < 3 {
+		fmt.Fprintf(l.out, "%s <cvar> <value>\n", l.cmds.Arg(0))
+		return
+	}
+
+	if l.cmds.ArgCount() > 3 {
+		// TODO: Add warning log
+		fmt.Fprintf(l.out, "%s \"%s\" command with extra args\n", l.cmds.Arg(0), varName)
+		return
+	}
+
+	cvar := l.Create(varName, varValue)
+	if cvar == nil {
+		fmt.Fprintf(l.out, "%s could not create a cvar named \"%s\"", l.cmds.Arg(0), varName)
+		return
+	}
+
+	l.SetQuick(cvar, varValue)
+
+	if l.cmds.Arg(0) == "seta" {
+		cvar.Flags |= FlagArchive | FlagSeta
+	}
+}
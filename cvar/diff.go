@@ -0,0 +1,118 @@
+package cvar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var cvarDiffFlagNames = map[string]Flags{
+	"archive":     FlagArchive,
+	"notify":      FlagNotify,
+	"serverinfo":  FlagServerInfo,
+	"userinfo":    FlagUserInfo,
+	"latched":     FlagLatched,
+	"userdefined": FlagUserDefined,
+}
+
+// ChangedVars returns the cvars whose value differs from their default, in alphabetical order.
+// Only cvars starting with prefix and having all of withFlags are included.
+func (l *Library) ChangedVars(prefix string, withFlags Flags) []*CVar {
+	var changed []*CVar
+	for _, v := range l.sortedVars {
+		if !strings.HasPrefix(v.Name, prefix) || v.Flags&withFlags != withFlags {
+			continue
+		}
+
+		pending, isPending := v.Pending()
+		if v.StringVal != v.DefaultString || (isPending && pending != v.DefaultString) {
+			changed = append(changed, v)
+		}
+	}
+
+	return changed
+}
+
+func (l *Library) CmdDiff() {
+	var prefix string
+	var withFlags Flags
+
+	for i := 1; i < l.cmds.ArgCount(); i++ {
+		arg := l.cmds.Arg(i)
+		if !strings.HasPrefix(arg, "-") {
+			prefix = arg
+			continue
+		}
+
+		flag, ok := cvarDiffFlagNames[arg[1:]]
+		if !ok {
+			names := make([]string, 0, len(cvarDiffFlagNames))
+			for name := range cvarDiffFlagNames {
+				names = append(names, "-"+name)
+			}
+			sort.Strings(names)
+
+			fmt.Fprintln(l.out, "cvardiff [prefix] [flags] : list cvars changed from their defaults")
+			fmt.Fprintf(l.out, "flags are %s\n", strings.Join(names, " "))
+			return
+		}
+		withFlags |= flag
+	}
+
+	changed := l.ChangedVars(prefix, withFlags)
+	for _, v := range changed {
+		pending, isPending := v.Pending()
+		if isPending {
+			fmt.Fprintf(l.out, "%s \"%s\" (pending \"%s\", default \"%s\")\n", v.Name, v.StringVal, pending, v.DefaultString)
+		} else {
+			fmt.Fprintf(l.out, "%s \"%s\" (default \"%s\")\n", v.Name, v.StringVal, v.DefaultString)
+		}
+	}
+
+	fmt.Fprintf(l.out, "%d cvars changed from default\n", len(changed))
+}
+
+// CmdDiffFile compares current values to the cvars set by a config file, without executing it
+func (l *Library) CmdDiffFile() {
+	if l.cmds.ArgCount() != 2 {
+		fmt.Fprintln(l.out, "cvardiff_file <filename> : compare cvars to the values set by a config file")
+		return
+	}
+
+	contents, found := l.host.LoadScript(l.cmds.Arg(1))
+	if !found {
+		fmt.Fprintf(l.out, "couldn't load %s\n", l.cmds.Arg(1))
+		return
+	}
+
+	// Later lines override earlier ones, as they would if the file were executed
+	fileValues := make(map[string]string)
+	var names []string
+	for _, assignment := range l.ParseAssignments(contents) {
+		if !assignment.Set && !l.Exists(assignment.Name) {
+			// Not a cvar, executing the line would report an unknown command
+			continue
+		}
+
+		_, seen := fileValues[assignment.Name]
+		if !seen {
+			names = append(names, assignment.Name)
+		}
+		fileValues[assignment.Name] = assignment.Value
+	}
+	sort.Strings(names)
+
+	var count int
+	for _, name := range names {
+		v := l.FindVar(name)
+		if v == nil {
+			fmt.Fprintf(l.out, "%s (not defined) (file \"%s\")\n", name, fileValues[name])
+			count++
+		} else if v.StringVal != fileValues[name] {
+			fmt.Fprintf(l.out, "%s \"%s\" (file \"%s\")\n", v.Name, v.StringVal, fileValues[name])
+			count++
+		}
+	}
+
+	fmt.Fprintf(l.out, "%d cvars differ from %s\n", count, l.cmds.Arg(1))
+}
@@ -0,0 +1,34 @@
+package cvar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vkngwrapper/quake/command"
+)
+
+func TestCmdDiffFile(t *testing.T) {
+	l, cmds, host, out := newTestLibrary()
+	cmds.Init()
+	l.Init()
+	cmds.Add("bind", func() {}, command.SourceCommand)
+
+	l.Register(&CVar{Name: "fov", StringVal: "90"})
+	l.Register(&CVar{Name: "sensitivity", StringVal: "3"})
+	host.scripts["test.cfg"] = testAutoexecCfg + "seta r_undefined \"1\"\nnot_a_cvar 1\n"
+
+	cmds.ExecuteString("cvardiff_file test.cfg", command.SourceCommand)
+
+	want := []string{
+		`crosshair (not defined) (file "1")`,
+		`fov "90" (file "110")`,
+		`r_undefined (not defined) (file "1")`,
+		`r_wateralpha (not defined) (file "0.6")`,
+		`sensitivity "3" (file "6")`,
+		`5 cvars differ from test.cfg`,
+	}
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
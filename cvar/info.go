@@ -0,0 +1,137 @@
+package cvar
+
+import (
+	"fmt"
+	"strings"
+)
+
+const MaxInfoString int = 196
+const MaxServerInfoString int = 512
+const MaxInfoKey int = 64
+
+// InfoPair is one key and value from an info string
+type InfoPair struct {
+	Key   string
+	Value string
+}
+
+// InfoString is a set of keys and values encoded as \key\value\key\value, which is how
+// QuakeWorld sends server and client settings over the network
+type InfoString struct {
+	maxSize int
+	text    string
+}
+
+// NewInfoString creates an empty info string that can grow to maxSize bytes
+func NewInfoString(maxSize int) *InfoString {
+	return &InfoString{maxSize: maxSize}
+}
+
+// String returns the encoded info string
+func (i *InfoString) String() string {
+	return i.text
+}
+
+// Pairs returns every key and value in the order they were added
+func (i *InfoString) Pairs() []InfoPair {
+	return ParseInfoPairs(i.text)
+}
+
+// Value returns the value stored for key, or an empty string if there isn't one
+func (i *InfoString) Value(key string) string {
+	for _, pair := range i.Pairs() {
+		if pair.Key == key {
+			return pair.Value
+		}
+	}
+
+	return ""
+}
+
+// Set stores a value for key, replacing any existing value.  An empty value removes the key.
+// The info string is left unchanged if the key or value are invalid, or if the result would
+// be too long.
+func (i *InfoString) Set(key string, value string) error {
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+
+	err := ValidateInfoText(key)
+	if err == nil {
+		err = ValidateInfoText(value)
+	}
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	for _, pair := range i.Pairs() {
+		if pair.Key != key {
+			writeInfoPair(&text, pair.Key, pair.Value)
+		}
+	}
+
+	if value != "" {
+		writeInfoPair(&text, key, value)
+	}
+
+	if text.Len() > i.maxSize {
+		return fmt.Errorf("info string length exceeded (%d max)", i.maxSize)
+	}
+
+	i.text = text.String()
+	return nil
+}
+
+// Remove deletes key from the info string
+func (i *InfoString) Remove(key string) {
+	_ = i.Set(key, "")
+}
+
+func writeInfoPair(text *strings.Builder, key string, value string) {
+	text.WriteRune('\\')
+	text.WriteString(key)
+	text.WriteRune('\\')
+	text.WriteString(value)
+}
+
+// ValidateInfoText rejects keys and values that would break the encoding or the commands
+// that info strings are sent in
+func ValidateInfoText(text string) error {
+	if len(text) >= MaxInfoKey {
+		return fmt.Errorf("keys and values must be shorter than %d characters", MaxInfoKey)
+	}
+
+	for _, r := range text {
+		switch {
+		case r == '\\':
+			return fmt.Errorf("can't use backslashes")
+		case r == '"':
+			return fmt.Errorf("can't use quotes")
+		case r < ' ':
+			return fmt.Errorf("can't use control characters")
+		}
+	}
+
+	return nil
+}
+
+// ParseInfoPairs decodes an info string.  A key without a value is given an empty value.
+func ParseInfoPairs(text string) []InfoPair {
+	text = strings.TrimPrefix(text, "\\")
+	if text == "" {
+		return nil
+	}
+
+	parts := strings.Split(text, "\\")
+	pairs := make([]InfoPair, 0, (len(parts)+1)/2)
+	for index := 0; index < len(parts); index += 2 {
+		pair := InfoPair{Key: parts[index]}
+		if index+1 < len(parts) {
+			pair.Value = parts[index+1]
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return pairs
+}
@@ -0,0 +1,70 @@
+package cvar
+
+import "fmt"
+
+// LatchGroup identifies the subsystem that commits a latched cvar's pending value
+type LatchGroup int
+
+const (
+	LatchVideo LatchGroup = iota
+	LatchMap
+	LatchServer
+)
+
+func (g LatchGroup) String() string {
+	switch g {
+	case LatchVideo:
+		return "vid_restart"
+	case LatchMap:
+		return "map change"
+	case LatchServer:
+		return "server restart"
+	}
+
+	return fmt.Sprintf("latch group %d", int(g))
+}
+
+// Pending returns the value that will be applied to a latched cvar the next time its
+// latch group is committed
+func (v *CVar) Pending() (string, bool) {
+	return v.LatchedString, v.latchPending
+}
+
+// latch stores a new value for a latched cvar without applying it.  Values can be applied
+// immediately until the host has finished initializing, so that config files read at startup
+// take effect.
+func (l *Library) latch(v *CVar, value string) bool {
+	if v.Flags&FlagLatched == 0 || !l.host.Initialized() {
+		return false
+	}
+
+	if value == v.StringVal {
+		// Setting the current value cancels any pending change
+		v.LatchedString = ""
+		v.latchPending = false
+		return true
+	}
+
+	if !v.latchPending || v.LatchedString != value {
+		fmt.Fprintf(l.out, "%s will be changed to \"%s\" after %s\n", v.Name, value, v.LatchGroup)
+	}
+
+	v.LatchedString = value
+	v.latchPending = true
+	return true
+}
+
+// CommitLatched applies the pending values of all latched cvars in the group.  It should be
+// called by the subsystem that owns the group at the point where changes are safe to make.
+func (l *Library) CommitLatched(group LatchGroup) {
+	for _, v := range l.sortedVars {
+		if v.Flags&FlagLatched == 0 || v.LatchGroup != group || !v.latchPending {
+			continue
+		}
+
+		value := v.LatchedString
+		v.LatchedString = ""
+		v.latchPending = false
+		l.apply(v, value)
+	}
+}
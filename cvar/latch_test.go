@@ -0,0 +1,86 @@
+package cvar
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vkngwrapper/quake/command"
+)
+
+type testHost struct {
+	initialized bool
+	scripts     map[string]string
+}
+
+func (h *testHost) Initialized() bool   { return h.initialized }
+func (h *testHost) WarnCommands() bool  { return false }
+func (h *testHost) CommandLine() string { return "" }
+
+func (h *testHost) LoadScript(fileName string) ([]byte, bool) {
+	script, ok := h.scripts[fileName]
+	return []byte(script), ok
+}
+
+func (h *testHost) UserDirPath(fileName string) string { return fileName }
+
+func newTestLibrary() (*Library, *command.Executor, *testHost, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	host := &testHost{scripts: make(map[string]string)}
+	cmds := command.New(out, host)
+	return New(out, cmds, host), cmds, host, out
+}
+
+func TestCommitLatched(t *testing.T) {
+	l, _, host, _ := newTestLibrary()
+	mapVar := &CVar{Name: "test_map", StringVal: "1", Flags: FlagLatched, LatchGroup: LatchMap}
+	serverVar := &CVar{Name: "test_server", StringVal: "1", Flags: FlagLatched, LatchGroup: LatchServer}
+	l.Register(mapVar)
+	l.Register(serverVar)
+
+	// Before the host is initialized, changes apply right away
+	l.SetQuick(mapVar, "2")
+	if mapVar.StringVal != "2" {
+		t.Fatalf("expected test_map to change to 2 during startup, got %s", mapVar.StringVal)
+	}
+
+	host.initialized = true
+	l.SetQuick(mapVar, "3")
+	l.SetQuick(serverVar, "3")
+	if mapVar.StringVal != "2" || serverVar.StringVal != "1" {
+		t.Fatalf("latched cvars changed before being committed: %s, %s", mapVar.StringVal, serverVar.StringVal)
+	}
+	if pending, ok := mapVar.Pending(); !ok || pending != "3" {
+		t.Fatalf("expected 3 to be pending for test_map, got %q, %t", pending, ok)
+	}
+
+	l.CommitLatched(LatchServer)
+	if mapVar.StringVal != "2" || serverVar.StringVal != "3" {
+		t.Fatalf("committing the server group gave %s, %s", mapVar.StringVal, serverVar.StringVal)
+	}
+
+	l.CommitLatched(LatchMap)
+	if mapVar.StringVal != "3" || mapVar.Value != 3 {
+		t.Fatalf("committing the map group gave %s (%g)", mapVar.StringVal, mapVar.Value)
+	}
+	if _, ok := mapVar.Pending(); ok {
+		t.Fatal("test_map still has a pending value after being committed")
+	}
+}
+
+func TestLatchCancel(t *testing.T) {
+	l, _, host, _ := newTestLibrary()
+	v := &CVar{Name: "test_map", StringVal: "1", Flags: FlagLatched, LatchGroup: LatchMap}
+	l.Register(v)
+	host.initialized = true
+
+	l.SetQuick(v, "2")
+	l.SetQuick(v, "1")
+	if _, ok := v.Pending(); ok {
+		t.Fatal("setting the current value should cancel the pending change")
+	}
+
+	l.CommitLatched(LatchMap)
+	if v.StringVal != "1" {
+		t.Fatalf("expected test_map to stay 1, got %s", v.StringVal)
+	}
+}
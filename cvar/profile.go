@@ -0,0 +1,217 @@
+package cvar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+const ProfileDirName = "profiles"
+
+// ProfilePath returns the file that a named cvar profile is stored in
+func (l *Library) ProfilePath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\:") {
+		return "", fmt.Errorf("invalid profile name \"%s\"", name)
+	}
+
+	return l.host.UserDirPath(path.Join(ProfileDirName, name+".cfg")), nil
+}
+
+// ListProfiles returns the names of all saved profiles in alphabetical order
+func (l *Library) ListProfiles() ([]string, error) {
+	entries, err := os.ReadDir(l.host.UserDirPath(ProfileDirName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".cfg" {
+			continue
+		}
+
+		names = append(names, strings.TrimSuffix(entry.Name(), ".cfg"))
+	}
+
+	return names, nil
+}
+
+// SaveProfile writes the current value of every archived cvar to the named profile, replacing
+// the profile if it already exists
+func (l *Library) SaveProfile(name string) error {
+	profilePath, err := l.ProfilePath(name)
+	if err != nil {
+		return err
+	}
+
+	var contents bytes.Buffer
+	err = l.WriteVariables(&contents)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(profilePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(profilePath, contents.Bytes(), 0644)
+}
+
+// ReadProfile returns the cvar values stored in the named profile
+func (l *Library) ReadProfile(name string) ([]Assignment, error) {
+	profilePath, err := l.ProfilePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := os.ReadFile(profilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no profile named \"%s\"", name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return l.ParseAssignments(contents), nil
+}
+
+// ApplyProfile sets every cvar stored in the named profile.  All values are checked before
+// any are set, so a profile that can't be applied in full changes nothing.  Change
+// notifications are sent as a single batch once every value is in place.
+func (l *Library) ApplyProfile(name string) error {
+	assignments, err := l.ReadProfile(name)
+	if err != nil {
+		return err
+	}
+
+	values := make([]string, len(assignments))
+	for i, assignment := range assignments {
+		values[i] = assignment.Value
+
+		v := l.FindVar(assignment.Name)
+		if v == nil {
+			if l.cmds.Exists(assignment.Name) {
+				return fmt.Errorf("%s is a command", assignment.Name)
+			}
+			continue
+		}
+
+		if v.Flags&(FlagROM|FlagLocked) != 0 {
+			return fmt.Errorf("%s is read-only", v.Name)
+		}
+
+		values[i], err = l.validate(v, assignment.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+	}
+
+	l.BeginBatch()
+	defer l.EndBatch()
+
+	for i, assignment := range assignments {
+		v := l.FindVar(assignment.Name)
+		if v == nil {
+			// Profiles only contain archived cvars, so user-defined ones were created with seta
+			v = l.Create(assignment.Name, values[i])
+			v.Flags |= FlagArchive | FlagSeta
+			continue
+		}
+
+		if !l.latch(v, values[i]) {
+			l.apply(v, values[i])
+		}
+	}
+
+	return nil
+}
+
+func (l *Library) CmdProfileSave() {
+	if l.cmds.ArgCount() != 2 {
+		fmt.Fprintln(l.out, "profile_save <name> : save archived cvars to a profile")
+		return
+	}
+
+	err := l.SaveProfile(l.cmds.Arg(1))
+	if err != nil {
+		fmt.Fprintf(l.out, "Couldn't save profile: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(l.out, "Saved profile \"%s\"\n", l.cmds.Arg(1))
+}
+
+func (l *Library) CmdProfileList() {
+	names, err := l.ListProfiles()
+	if err != nil {
+		fmt.Fprintf(l.out, "Couldn't list profiles: %s\n", err)
+		return
+	}
+
+	for _, name := range names {
+		fmt.Fprintf(l.out, "   %s\n", name)
+	}
+
+	if len(names) == 1 {
+		fmt.Fprintln(l.out, "1 profile")
+	} else {
+		fmt.Fprintf(l.out, "%d profiles\n", len(names))
+	}
+}
+
+func (l *Library) CmdProfileDiff() {
+	argCount := l.cmds.ArgCount()
+	if argCount < 2 || argCount > 3 || (argCount == 3 && l.cmds.Arg(2) != "default") {
+		fmt.Fprintln(l.out, "profile_diff <name> [default] : compare a profile to current or default values")
+		return
+	}
+
+	assignments, err := l.ReadProfile(l.cmds.Arg(1))
+	if err != nil {
+		fmt.Fprintf(l.out, "Couldn't read profile: %s\n", err)
+		return
+	}
+	againstDefaults := argCount == 3
+
+	var count int
+	for _, assignment := range assignments {
+		v := l.FindVar(assignment.Name)
+		if v == nil {
+			fmt.Fprintf(l.out, "%s (not defined) -> \"%s\"\n", assignment.Name, assignment.Value)
+			count++
+			continue
+		}
+
+		value := v.StringVal
+		if againstDefaults {
+			value = v.DefaultString
+		}
+
+		if value != assignment.Value {
+			fmt.Fprintf(l.out, "%s \"%s\" -> \"%s\"\n", v.Name, value, assignment.Value)
+			count++
+		}
+	}
+
+	if count == 0 {
+		fmt.Fprintln(l.out, "no differences")
+	}
+}
+
+func (l *Library) CmdProfileApply() {
+	if l.cmds.ArgCount() != 2 {
+		fmt.Fprintln(l.out, "profile_apply <name> : set cvars from a profile")
+		return
+	}
+
+	err := l.ApplyProfile(l.cmds.Arg(1))
+	if err != nil {
+		fmt.Fprintf(l.out, "Couldn't apply profile: %s\n", err)
+	}
+}
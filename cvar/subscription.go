@@ -0,0 +1,101 @@
+package cvar
+
+// UnsubscribeFunc removes the subscription that returned it.  Calling it more than once
+// does nothing.
+type UnsubscribeFunc func()
+
+type cvarSubscriber struct {
+	callback CallbackFunc
+}
+
+// Subscribe registers a callback that runs whenever the cvar's value changes, after the cvar's
+// own Callback.  Any number of subscribers can watch the same cvar.
+func (l *Library) Subscribe(v *CVar, callback CallbackFunc) UnsubscribeFunc {
+	if l.subscribers == nil {
+		l.subscribers = make(map[*CVar][]*cvarSubscriber)
+	}
+
+	subscriber := &cvarSubscriber{callback: callback}
+	l.subscribers[v] = append(l.subscribers[v], subscriber)
+
+	return func() {
+		remaining := removeCVarSubscriber(l.subscribers[v], subscriber)
+		if len(remaining) == 0 {
+			delete(l.subscribers, v)
+		} else {
+			l.subscribers[v] = remaining
+		}
+	}
+}
+
+// SubscribeAll registers a callback that runs whenever any cvar's value changes
+func (l *Library) SubscribeAll(callback CallbackFunc) UnsubscribeFunc {
+	subscriber := &cvarSubscriber{callback: callback}
+	l.globalSubscribers = append(l.globalSubscribers, subscriber)
+
+	return func() {
+		l.globalSubscribers = removeCVarSubscriber(l.globalSubscribers, subscriber)
+	}
+}
+
+// removeCVarSubscriber builds a new slice rather than modifying the existing one, so that
+// callbacks can unsubscribe while the subscribers are being notified
+func removeCVarSubscriber(subscribers []*cvarSubscriber, subscriber *cvarSubscriber) []*cvarSubscriber {
+	remaining := make([]*cvarSubscriber, 0, len(subscribers))
+	for _, existing := range subscribers {
+		if existing != subscriber {
+			remaining = append(remaining, existing)
+		}
+	}
+
+	return remaining
+}
+
+// BeginBatch holds back change notifications until the matching EndBatch, so that a group of
+// changes can be applied together.  Batches can be nested.
+func (l *Library) BeginBatch() {
+	l.batchDepth++
+}
+
+// EndBatch ends a batch.  When the outermost batch ends, each cvar that changed during it is
+// notified once, in the order they first changed.
+func (l *Library) EndBatch() {
+	if l.batchDepth == 0 {
+		return
+	}
+
+	l.batchDepth--
+	if l.batchDepth > 0 {
+		return
+	}
+
+	changed := l.batched
+	l.batched = nil
+	for _, v := range changed {
+		l.notify(v)
+	}
+}
+
+func (l *Library) notify(v *CVar) {
+	if l.batchDepth > 0 {
+		for _, batched := range l.batched {
+			if batched == v {
+				return
+			}
+		}
+		l.batched = append(l.batched, v)
+		return
+	}
+
+	if v.Callback != nil {
+		v.Callback(v)
+	}
+
+	for _, subscriber := range l.subscribers[v] {
+		subscriber.callback(v)
+	}
+
+	for _, subscriber := range l.globalSubscribers {
+		subscriber.callback(v)
+	}
+}
@@ -0,0 +1,136 @@
+package cvar
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Type describes the values a cvar accepts.  Cvars with TypeAny accept anything.
+type Type int
+
+const (
+	TypeAny Type = iota
+	TypeBool
+	TypeInt
+	TypeFloat
+	TypeEnum
+	TypeString
+)
+
+// hasRange reports whether Min and Max restrict an int or float cvar
+func (v *CVar) hasRange() bool {
+	return v.Min != v.Max
+}
+
+// validate checks a new value against the cvar's type metadata.  It returns the value that
+// should be stored, which differs from the requested value when a number had to be clamped
+// into range, or an error explaining why the value was rejected.
+func (l *Library) validate(v *CVar, value string) (string, error) {
+	if v.Flags&(FlagServerInfo|FlagUserInfo) != 0 {
+		// The value has to fit in an info string
+		err := ValidateInfoText(value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch v.Type {
+	case TypeBool:
+		// Like vkQuake, any number is accepted and anything other than 0 is on, so configs
+		// holding values like "1.000000" still load
+		numVal, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(numVal) {
+			return "", fmt.Errorf("\"%s\" is not a number", value)
+		}
+	case TypeInt, TypeFloat:
+		numVal, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(numVal) || math.IsInf(numVal, 0) {
+			return "", fmt.Errorf("\"%s\" is not a number", value)
+		}
+
+		if v.Type == TypeInt && numVal != math.Trunc(numVal) {
+			return "", fmt.Errorf("\"%s\" is not a whole number", value)
+		}
+
+		if v.hasRange() && (numVal < v.Min || numVal > v.Max) {
+			clamped := FormatFloat(math.Min(math.Max(numVal, v.Min), v.Max))
+			fmt.Fprintf(l.out, "%s: \"%s\" is outside %s, clamped to %s\n", v.Name, value, v.Constraint(), clamped)
+			return clamped, nil
+		}
+	case TypeEnum:
+		for _, allowed := range v.Values {
+			if value == allowed {
+				return value, nil
+			}
+		}
+
+		return "", fmt.Errorf("\"%s\" is not one of %s", value, strings.Join(v.Values, ", "))
+	case TypeString:
+		if v.MaxLength > 0 && len(value) > v.MaxLength {
+			return "", fmt.Errorf("value is longer than %d characters", v.MaxLength)
+		}
+	}
+
+	return value, nil
+}
+
+// Constraint describes the values the cvar accepts, or returns an empty string if it accepts
+// any value
+func (v *CVar) Constraint() string {
+	switch v.Type {
+	case TypeBool:
+		return "[0/1]"
+	case TypeInt, TypeFloat:
+		if v.hasRange() {
+			return fmt.Sprintf("[%s-%s]", FormatFloat(v.Min), FormatFloat(v.Max))
+		}
+		if v.Type == TypeInt {
+			return "[integer]"
+		}
+		return "[number]"
+	case TypeEnum:
+		return "{" + strings.Join(v.Values, ",") + "}"
+	case TypeString:
+		if v.MaxLength > 0 {
+			return fmt.Sprintf("[%d chars max]", v.MaxLength)
+		}
+	}
+
+	return ""
+}
+
+// CompleteVariableValue returns the values that the cvar accepts that begin with partialValue,
+// for cvars that only accept a fixed set of values
+func (l *Library) CompleteVariableValue(v *CVar, partialValue string) []string {
+	var candidates []string
+
+	switch v.Type {
+	case TypeBool:
+		candidates = []string{"0", "1"}
+	case TypeEnum:
+		candidates = v.Values
+	default:
+		return nil
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, partialValue) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	return matches
+}
+
+func FormatFloat(value float64) string {
+	intVal := int64(value + 0.5)
+
+	if math.Abs(value-float64(intVal)) < 0.0001 {
+		return strconv.FormatInt(intVal, 10)
+	}
+
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
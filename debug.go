@@ -2,7 +2,9 @@
 
 package main
 
-var CVarRenderRayDebug = CVar{
+import "github.com/vkngwrapper/quake/cvar"
+
+var CVarRenderRayDebug = cvar.CVar{
 	Name:      "r_raydebug",
 	StringVal: "0",
 }
@@ -0,0 +1,455 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/vkngwrapper/quake/crc"
+)
+
+const GameName = "id1"
+const MaxFilesInPack = 2048
+const PakFileSize = 64
+const PakNameLength = 56
+const Pak0FileCount = 339
+const (
+	Pak0CrcV100 uint16 = 13900
+	Pak0CrcV101        = 62751
+	Pak0CrcV106        = 32981
+)
+
+var PackID = [4]byte{'P', 'A', 'C', 'K'}
+
+// PackHeader is found at the start of every pak file
+type PackHeader struct {
+	ID        [4]byte
+	DirOffset int32
+	DirSize   int32
+}
+
+// PackDirEntry is the pak directory's record of one of the files in the pak
+type PackDirEntry struct {
+	Name    [PakNameLength]byte
+	FilePos int32
+	FileLen int32
+}
+
+// FileName returns the entry's name without the padding that fills out the name field
+func (e *PackDirEntry) FileName() string {
+	name := e.Name[:]
+	nullIndex := bytes.IndexByte(name, 0)
+	if nullIndex >= 0 {
+		name = name[:nullIndex]
+	}
+
+	return string(name)
+}
+
+type BytesFile struct {
+	bytes.Reader
+}
+
+func (f *BytesFile) Close() error {
+	return nil
+}
+
+type PackFile struct {
+	name    string
+	filePos int
+	fileLen int
+}
+
+type GamePack struct {
+	fileName string
+	handle   io.ReadSeekCloser
+	files    []PackFile
+}
+
+type SearchPath struct {
+	pathId   int
+	fileName string
+	pack     *GamePack
+	dir      string
+	next     *SearchPath
+}
+
+type Options struct {
+	BaseDir string
+	// UserDir is where files are written, if it isn't BaseDir
+	UserDir string
+	// EnginePak is the engine's own pak, searched after pak0.pak of the first game directory.
+	// Leave it nil to only use the game's paks.
+	EnginePak io.ReadSeekCloser
+}
+
+type FileSystem struct {
+	baseDir   string
+	userDir   string
+	gameDir   string
+	gameNames string
+
+	modified   bool
+	registered bool
+	enginePak  io.ReadSeekCloser
+
+	searchPaths     *SearchPath
+	baseSearchPaths *SearchPath
+}
+
+// New creates a filesystem with no game directories.  Files in subdirectories of game
+// directories are available until CheckRegistered finds that the game isn't registered.
+func New(options Options) *FileSystem {
+	userDir := options.UserDir
+	if userDir == options.BaseDir {
+		userDir = ""
+	}
+
+	return &FileSystem{
+		baseDir:    options.BaseDir,
+		userDir:    userDir,
+		registered: true,
+		enginePak:  options.EnginePak,
+	}
+}
+
+func (f *FileSystem) addPath(pathId int, dir string) error {
+	search := &SearchPath{
+		pathId:   pathId,
+		fileName: f.gameDir,
+		dir:      dir,
+		next:     f.searchPaths,
+	}
+	f.searchPaths = search
+
+	for pakIndex := 0; ; pakIndex++ {
+		pakFile := path.Join(f.gameDir, fmt.Sprintf("pak%d.pak", pakIndex))
+		file, err := os.Open(pakFile)
+		if err != nil {
+			return nil
+		}
+		pak, err := f.LoadPackFile(pakFile, file)
+		if err != nil {
+			return err
+		}
+		if pak != nil {
+			search = &SearchPath{
+				pathId: pathId,
+				pack:   pak,
+				dir:    dir,
+				next:   f.searchPaths,
+			}
+			f.searchPaths = search
+		}
+
+		if pakIndex == 0 && pathId == 1 && f.enginePak != nil {
+			wasModified := f.modified
+			_, _ = f.enginePak.Seek(0, io.SeekStart)
+			pak, err = f.LoadPackFile("vkQuake.pak", f.enginePak)
+			if err != nil {
+				return err
+			}
+			search = &SearchPath{
+				pathId: pathId,
+				pack:   pak,
+				dir:    dir,
+				next:   f.searchPaths,
+			}
+			f.searchPaths = search
+			f.modified = wasModified
+		}
+
+		if pak == nil {
+			return nil
+		}
+	}
+}
+
+func (f *FileSystem) AddGameDirectory(dir string) error {
+	if f.gameNames != "" {
+		f.gameNames += ";"
+	}
+	f.gameNames += dir
+
+	f.gameDir = path.Join(f.baseDir, dir)
+
+	var pathId int
+	if f.searchPaths != nil {
+		pathId = f.searchPaths.pathId << 1
+	} else {
+		pathId = 1
+	}
+
+	err := f.addPath(pathId, dir)
+	if err != nil {
+		return err
+	}
+
+	if f.userDir != "" {
+		f.gameDir = path.Join(f.userDir, dir)
+		err = os.MkdirAll(f.gameDir, 0777)
+		if err != nil {
+			return fmt.Errorf("unable to create directory %s: %w", f.gameDir, err)
+		}
+		return f.addPath(pathId, dir)
+	}
+
+	return nil
+}
+
+// SetBaseGames makes the game directories added so far permanent, so that
+// ResetGameDirectories doesn't remove them
+func (f *FileSystem) SetBaseGames() {
+	f.baseSearchPaths = f.searchPaths
+}
+
+func (f *FileSystem) LoadPackFile(path string, file io.ReadSeekCloser) (*GamePack, error) {
+	var header PackHeader
+	err := binary.Read(file, binary.LittleEndian, &header)
+	if err != nil || header.ID != PackID {
+		return nil, fmt.Errorf("%s is not a packfile", path)
+	}
+	if header.DirOffset < 0 || header.DirSize < 0 {
+		return nil, fmt.Errorf("invalid packfile %s (dirSize: %d, dirOffset: %d)", path, header.DirSize, header.DirOffset)
+	}
+
+	numFiles := header.DirSize / int32(PakFileSize)
+	if numFiles < 1 {
+		log.Printf("WARNING: %s has no files, ignored\n", path)
+		_ = file.Close()
+		return nil, nil
+	}
+	if numFiles > MaxFilesInPack {
+		return nil, fmt.Errorf("%s has %d files", path, numFiles)
+	}
+	if numFiles != int32(Pak0FileCount) {
+		f.modified = true
+	}
+
+	fileDataBytes := make([]byte, header.DirSize)
+	_, _ = file.Seek(int64(header.DirOffset), io.SeekStart)
+	_, err = io.ReadFull(file, fileDataBytes)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the directory of %s: %w", path, err)
+	}
+
+	var crcValue uint16
+	crc.Init(&crcValue)
+	for _, b := range fileDataBytes {
+		crc.ProcessByte(&crcValue, b)
+	}
+
+	if crcValue != Pak0CrcV106 && crcValue != Pak0CrcV101 && crcValue != Pak0CrcV100 {
+		f.modified = true
+	}
+
+	entries := make([]PackDirEntry, numFiles)
+	_ = binary.Read(bytes.NewReader(fileDataBytes), binary.LittleEndian, entries)
+
+	fileData := make([]PackFile, numFiles)
+	for fileDataIndex, entry := range entries {
+		fileData[fileDataIndex].name = entry.FileName()
+		fileData[fileDataIndex].filePos = int(entry.FilePos)
+		fileData[fileDataIndex].fileLen = int(entry.FileLen)
+	}
+
+	return &GamePack{
+		fileName: path,
+		handle:   file,
+		files:    fileData,
+	}, nil
+}
+
+// ResetGameDirectories removes every game directory added since SetBaseGames, then adds the
+// ;-separated list of directories in newDirs
+func (f *FileSystem) ResetGameDirectories(newDirs string) error {
+	for f.searchPaths != f.baseSearchPaths {
+		if f.searchPaths.pack != nil {
+			_ = f.searchPaths.pack.handle.Close()
+		}
+		f.searchPaths = f.searchPaths.next
+	}
+
+	f.gameNames = ""
+
+	dir := f.baseDir
+	if f.userDir != "" {
+		dir = f.userDir
+	}
+
+	f.gameDir = path.Join(dir, GameName)
+
+	pathSegments := strings.Split(newDirs, ";")
+
+	for pathIndex, pathSeg := range pathSegments {
+		if pathSeg == GameName || pathSeg == "" {
+			// The base game was never actually unloaded
+			continue
+		}
+
+		// Make sure this isn't a duplicate
+		var firstMatch int
+		for firstMatch = 0; firstMatch < pathIndex; firstMatch++ {
+			if pathSegments[firstMatch] == pathSeg {
+				break
+			}
+		}
+
+		if firstMatch == pathIndex {
+			err := f.AddGameDirectory(pathSeg)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *FileSystem) FileExists(fileName string) bool {
+	size, _, _ := f.findFile(fileName, false)
+	return size > 0
+}
+
+func (f *FileSystem) OpenFile(fileName string) (size int, file BoundedReader, pathId int) {
+	return f.findFile(fileName, true)
+}
+
+func (f *FileSystem) LoadFile(fileName string) (data []byte, pathId int) {
+	size, file, pathId := f.OpenFile(fileName)
+	if size <= 0 {
+		return
+	}
+
+	data = make([]byte, size)
+	_, err := io.ReadFull(&file, data)
+	if err != nil {
+		return nil, pathId
+	}
+
+	_ = file.Close()
+	return
+}
+
+func (f *FileSystem) findFile(fileName string, openFile bool) (size int, file BoundedReader, pathId int) {
+	isConfig := fileName == "config.cfg"
+
+	for search := f.searchPaths; search != nil; search = search.next {
+		if search.pack != nil {
+			for _, entry := range search.pack.files {
+				if entry.name != fileName {
+					continue
+				}
+
+				pathId = search.pathId
+				size = entry.fileLen
+
+				if openFile {
+					file = BoundedReaderFromPackFile(entry, search.pack)
+				}
+
+				return
+			}
+		} else if !f.registered && (strings.Contains(fileName, "/") || strings.Contains(fileName, "\\")) {
+			continue
+		} else {
+			var netPath string
+			var found bool
+			var fileInfo os.FileInfo
+			var err error
+			if isConfig {
+				netPath = path.Join(search.fileName, "vkQuake.cfg")
+				fileInfo, err = os.Stat(netPath)
+				found = err == nil && fileInfo.Mode().IsRegular()
+			}
+
+			if !found {
+				netPath = path.Join(search.fileName, fileName)
+				fileInfo, err = os.Stat(netPath)
+				if err != nil || !fileInfo.Mode().IsRegular() {
+					continue
+				}
+			}
+
+			pathId = search.pathId
+			size = int(fileInfo.Size())
+
+			if openFile {
+				osFile, _ := os.Open(netPath)
+				file = BoundedReaderFromOSFile(osFile, size)
+			}
+
+			return
+		}
+	}
+
+	// TODO: Developer mode
+
+	return -1, BoundedReader{}, -1
+}
+
+// CheckRegistered looks for a file that only the registered version of the game has.  Files in
+// subdirectories of game directories are unavailable if it isn't found.
+func (f *FileSystem) CheckRegistered() bool {
+	f.registered = f.FileExists("gfx/pop.lmp")
+	return f.registered
+}
+
+func (f *FileSystem) Registered() bool {
+	return f.registered
+}
+
+// Modified reports whether any game directory or pak differs from the original game
+func (f *FileSystem) Modified() bool {
+	return f.modified
+}
+
+func (f *FileSystem) SetModified() {
+	f.modified = true
+}
+
+func (f *FileSystem) BaseDir() string {
+	return f.baseDir
+}
+
+// GameDir returns the writable directory of the most recently added game
+func (f *FileSystem) GameDir() string {
+	return f.gameDir
+}
+
+func (f *FileSystem) GameNames(full bool) string {
+	if !full {
+		return f.gameNames
+	}
+
+	if f.gameNames == "" {
+		return GameName
+	}
+
+	return fmt.Sprintf("%s;%s", GameName, f.gameNames)
+}
+
+// DescribeSearchPaths returns a line describing each search path, in the order they're searched
+func (f *FileSystem) DescribeSearchPaths() []string {
+	var lines []string
+	for s := f.searchPaths; s != nil; s = s.next {
+		if s.pack != nil {
+			lines = append(lines, fmt.Sprintf("%s (%d files)", s.pack.fileName, len(s.pack.files)))
+		} else {
+			lines = append(lines, s.fileName)
+		}
+	}
+
+	return lines
+}
+
+func ModForbiddenChars(path string) bool {
+	return path == "" || path == "." || strings.Contains(path, "..") ||
+		strings.Contains(path, string(os.PathSeparator)) || strings.Contains(path, ":") ||
+		strings.Contains(path, "\"") || strings.Contains(path, ";")
+}
@@ -6,177 +6,179 @@ import (
 
 	"github.com/vkngwrapper/core/v3/core1_0"
 	"github.com/vkngwrapper/math"
+	"github.com/vkngwrapper/quake/cvar"
+	"github.com/vkngwrapper/quake/model"
 )
 import stdmath "math"
 
-var CVarRDrawEntities = &CVar{
+var CVarRDrawEntities = &cvar.CVar{
 	Name:      "r_drawentities",
 	StringVal: "1",
 }
-var CVarRDrawViewModel = &CVar{
+var CVarRDrawViewModel = &cvar.CVar{
 	Name:      "r_drawviewmodel",
 	StringVal: "1",
 }
-var CVarRSpeeds = &CVar{
+var CVarRSpeeds = &cvar.CVar{
 	Name:      "r_speeds",
 	StringVal: "0",
 }
-var CVarRPos = &CVar{
+var CVarRPos = &cvar.CVar{
 	Name:      "r_pos",
 	StringVal: "0",
 }
-var CVarRFullBright = &CVar{
+var CVarRFullBright = &cvar.CVar{
 	Name:      "r_fullbright",
 	StringVal: "0",
 }
-var CVarRLightMap = &CVar{
+var CVarRLightMap = &cvar.CVar{
 	Name:      "r_lightmap",
 	StringVal: "0",
 }
-var CVarRWaterAlpha = &CVar{
+var CVarRWaterAlpha = &cvar.CVar{
 	Name:      "r_wateralpha",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRDynamic = &CVar{
+var CVarRDynamic = &cvar.CVar{
 	Name:      "r_dynamic",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRNovis = &CVar{
+var CVarRNovis = &cvar.CVar{
 	Name:      "r_novis",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRSIMD = &CVar{
+var CVarRSIMD = &cvar.CVar{
 	Name:      "r_simd",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRAlphaSort = &CVar{
+var CVarRAlphaSort = &cvar.CVar{
 	Name:      "r_alphasort",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
 
-var CVarGLFinish = &CVar{
+var CVarGLFinish = &cvar.CVar{
 	Name:      "gl_finish",
 	StringVal: "0",
 }
-var CVarGLPolyBlend = &CVar{
+var CVarGLPolyBlend = &cvar.CVar{
 	Name:      "gl_polyblend",
 	StringVal: "1",
 }
-var CVarGLNoColors = &CVar{
+var CVarGLNoColors = &cvar.CVar{
 	Name:      "gl_nocolors",
 	StringVal: "0",
 }
 
-var CVarRClearColor = &CVar{
+var CVarRClearColor = &cvar.CVar{
 	Name:      "r_clearcolor",
 	StringVal: "2",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRFastClear = &CVar{
+var CVarRFastClear = &cvar.CVar{
 	Name:      "r_fastclear",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRFlatLightStyles = &CVar{
+var CVarRFlatLightStyles = &cvar.CVar{
 	Name:      "r_flatlightstyles",
 	StringVal: "0",
 }
-var CVarRLerpLightStyles = &CVar{
+var CVarRLerpLightStyles = &cvar.CVar{
 	Name:      "r_lerplightstyles",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarGLFullBrights = &CVar{
+var CVarGLFullBrights = &cvar.CVar{
 	Name:      "gl_fullbrights",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarGLFarClip = &CVar{
+var CVarGLFarClip = &cvar.CVar{
 	Name:      "gl_farclip",
 	StringVal: "16384",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarROldSkyLeaf = &CVar{
+var CVarROldSkyLeaf = &cvar.CVar{
 	Name:      "r_oldskyleaf",
 	StringVal: "0",
 }
-var CVarRDrawWorld = &CVar{
+var CVarRDrawWorld = &cvar.CVar{
 	Name:      "r_drawworld",
 	StringVal: "1",
 }
-var CVarRShowTris = &CVar{
+var CVarRShowTris = &cvar.CVar{
 	Name:      "r_showtris",
 	StringVal: "0",
 }
-var CVarRShowBBoxes = &CVar{
+var CVarRShowBBoxes = &cvar.CVar{
 	Name:      "r_showbboxes",
 	StringVal: "0",
 }
-var CVarRShowBBoxesFilter = &CVar{
+var CVarRShowBBoxesFilter = &cvar.CVar{
 	Name:      "r_showbboxes_filter",
 	StringVal: "",
 }
-var CVarRLerpModels = &CVar{
+var CVarRLerpModels = &cvar.CVar{
 	Name:      "r_lerpmodels",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRLerpMove = &CVar{
+var CVarRLerpMove = &cvar.CVar{
 	Name:      "r_lerpmove",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRLerpTurn = &CVar{
+var CVarRLerpTurn = &cvar.CVar{
 	Name:      "r_lerpturn",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRNoLerpList = &CVar{
+var CVarRNoLerpList = &cvar.CVar{
 	Name: "r_nolerp_list",
 	StringVal: "progs/flame.mdl,progs/flame2.mdl,progs/braztall.mdl,progs/brazshrt.mdl,progs/longtrch.mdl," +
 		"progs/flame_pyre.mdl,progs/v_saw.mdl,progs/v_xfist.mdl,progs/h2stuff/newfire.mdl",
 }
-var CVarGLZFix = &CVar{
+var CVarGLZFix = &cvar.CVar{
 	Name:      "gl_zfix",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRLavaAlpha = &CVar{
+var CVarRLavaAlpha = &cvar.CVar{
 	Name:      "r_lavaalpha",
 	StringVal: "0",
 }
-var CVarRTeleAlpha = &CVar{
+var CVarRTeleAlpha = &cvar.CVar{
 	Name:      "r_telealpha",
 	StringVal: "0",
 }
-var CVarRSlimeAlpha = &CVar{
+var CVarRSlimeAlpha = &cvar.CVar{
 	Name:      "r_slimealpha",
 	StringVal: "0",
 }
-var CVarRScale = &CVar{
+var CVarRScale = &cvar.CVar{
 	Name:      "r_scale",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRGPULightMapUpdate = &CVar{
+var CVarRGPULightMapUpdate = &cvar.CVar{
 	Name:      "r_gpulightmapupdate",
 	StringVal: "1",
 }
-var CVarRRTShadows = &CVar{
+var CVarRRTShadows = &cvar.CVar{
 	Name:      "r_rtshadows",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarRTasks = &CVar{
+var CVarRTasks = &cvar.CVar{
 	Name:      "r_tasks",
 	StringVal: "1",
 }
-var CVarRIndirect = &CVar{
+var CVarRIndirect = &cvar.CVar{
 	Name:      "r_indirect",
 	StringVal: "1",
 }
@@ -186,7 +188,7 @@ const (
 )
 
 type CameraData struct {
-	planes [4]MPlane
+	planes [4]model.MPlane
 
 	viewNormal math.Vec3[float32]
 	viewUp     math.Vec3[float32]
@@ -196,8 +198,8 @@ type CameraData struct {
 	fovX float32
 	fovY float32
 
-	viewLeaf    *MLeaf
-	oldViewLeaf *MLeaf
+	viewLeaf    *model.MLeaf
+	oldViewLeaf *model.MLeaf
 
 	renderWarp  bool
 	renderScale int
@@ -267,7 +269,7 @@ func (f *CameraData) SetFrustum(fovX, fovY float32) {
 	f.planes[3].Normal.SetRotateTowards(&f.viewNormal, math.ToRadians(fovY/2.0-90), &f.viewUp)
 
 	for i := 0; i < 4; i++ {
-		f.planes[i].Type = byte(PlaneAnyZ)
+		f.planes[i].Type = byte(model.PlaneAnyZ)
 		f.planes[i].Dist = f.viewOrigin.DotProduct(&f.planes[i].Normal)
 		f.planes[i].SignBits = SignBitsForPlane(&f.planes[i])
 	}
@@ -311,7 +313,9 @@ func (c *CameraData) RenderSetupViewBeforeMark() {
 
 	// Current viewleaf
 	c.oldViewLeaf = c.viewLeaf
-	// TODO: Mod_PointINLeaf
+	if RenderData.WorldModel != nil {
+		c.viewLeaf = RenderData.WorldModel.PointInLeaf(c.viewOrigin)
+	}
 
 	c.fovX = RenderData.FovX
 	c.fovY = RenderData.FovY
@@ -329,6 +333,34 @@ func (c *CameraData) RenderSetupViewBeforeMark() {
 	// TODO: Client
 }
 
+// MarkSurfaces marks the world's surfaces in leafs that can be seen from the view leaf with visFrame
+func (c *CameraData) MarkSurfaces(world *model.QModel, visFrame int) {
+	// Check this leaf for water portals
+	nearWaterPortal := false
+	if c.viewLeaf != nil {
+		for _, surf := range world.MarkSurfaces[c.viewLeaf.FirstMarkSurface : c.viewLeaf.FirstMarkSurface+c.viewLeaf.NumMarkSurfaces] {
+			if world.Surfaces[surf].Flags&model.SurfDrawTurb != 0 {
+				nearWaterPortal = true
+			}
+		}
+	}
+
+	var vis []byte
+	if CVarRNovis.Value != 0 || c.viewLeaf == nil || c.viewLeaf.Contents == model.ContentsSolid || c.viewLeaf.Contents == model.ContentsSky {
+		vis = world.NoVisPVS()
+	} else if nearWaterPortal {
+		vis = world.FatPVS(c.viewOrigin)
+	} else {
+		vis = world.LeafPVS(c.viewLeaf)
+	}
+
+	world.MarkVisibleSurfaces(vis, visFrame, func(leaf *model.MLeaf) bool {
+		mins := math.Vec3[float32]{X: leaf.MinMaxs[0], Y: leaf.MinMaxs[1], Z: leaf.MinMaxs[2]}
+		maxs := math.Vec3[float32]{X: leaf.MinMaxs[3], Y: leaf.MinMaxs[4], Z: leaf.MinMaxs[5]}
+		return c.CullBox(&mins, &maxs)
+	})
+}
+
 func RotateForEntity(matrix *math.Mat4x4[float32], origin *math.Vec3[float32], angles *math.Vec3[float32], scale uint8) {
 	matrix.Translate(origin.X, origin.Y, origin.Z)
 
@@ -342,7 +374,7 @@ func RotateForEntity(matrix *math.Mat4x4[float32], origin *math.Vec3[float32], a
 	}
 }
 
-func SignBitsForPlane(plane *MPlane) byte {
+func SignBitsForPlane(plane *model.MPlane) byte {
 	var bits byte
 	if plane.Normal.X < 0 {
 		bits |= 1
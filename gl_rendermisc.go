@@ -4,17 +4,18 @@ import (
 	"log"
 
 	"github.com/vkngwrapper/core/v3/core1_0"
+	"github.com/vkngwrapper/quake/cvar"
 )
 
-var CVarRLodBias = &CVar{
+var CVarRLodBias = &cvar.CVar{
 	Name:      "r_lodbias",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarGLLodBias = &CVar{
+var CVarGLLodBias = &cvar.CVar{
 	Name:      "gl_lodbias",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
 
 const (
@@ -75,15 +76,15 @@ func (v *VulkanGlobals) SetClearColor() {
 	}
 }
 
-func (v *VulkanGlobals) CVarSetClearColor(cvar *CVar) {
+func (v *VulkanGlobals) CVarSetClearColor(_ *cvar.CVar) {
 	if CVarRFastClear.Value != 0.0 {
-		log.Println("Black clear color forced by r_fastclear")
+		Console.Println("Black clear color forced by r_fastclear")
 	}
 
 	v.SetClearColor()
 }
 
-func (v *VulkanGlobals) CVarSetFastClear(cvar *CVar) {
+func (v *VulkanGlobals) CVarSetFastClear(_ *cvar.CVar) {
 	v.SetClearColor()
 }
 
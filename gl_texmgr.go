@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/vkngwrapper/arsenal/vam"
 	"github.com/vkngwrapper/core/v3/core1_0"
+	"github.com/vkngwrapper/quake/model"
 )
 
 var D8To24Table [256]uint32
@@ -47,7 +48,7 @@ const (
 type GLTexture struct {
 	Next *GLTexture
 
-	Owner *QModel
+	Owner *model.QModel
 
 	// managed by image loading
 	Name         string
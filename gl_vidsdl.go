@@ -5,6 +5,7 @@ import (
 
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/vkngwrapper/core/v3/core1_0"
+	"github.com/vkngwrapper/quake/cvar"
 )
 
 const (
@@ -12,80 +13,99 @@ const (
 	InitialStagingBufferSizeKB int = 16384
 )
 
-var CVarVidFullScreen = CVar{
+var CVarVidFullScreen = cvar.CVar{
 	Name:      "vid_fullscreen",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeInt,
+	Min:       0,
+	Max:       2,
 }
-var CVarVidWidth = CVar{
+var CVarVidWidth = cvar.CVar{
 	Name:      "vid_width",
 	StringVal: "1280",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarVidHeight = CVar{
+var CVarVidHeight = cvar.CVar{
 	Name:      "vid_height",
 	StringVal: "720",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarVidRefreshRate = CVar{
+var CVarVidRefreshRate = cvar.CVar{
 	Name:      "vid_refreshrate",
 	StringVal: "60",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
 }
-var CVarVidVSync = CVar{
+var CVarVidVSync = cvar.CVar{
 	Name:      "vid_vsync",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeBool,
 }
-var CVarVidDesktopFullscreen = CVar{
+var CVarVidDesktopFullscreen = cvar.CVar{
 	Name:      "vid_desktopfullscreen",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeBool,
 }
-var CVarVidBorderless = CVar{
+var CVarVidBorderless = cvar.CVar{
 	Name:      "vid_borderless",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeBool,
 }
-var CVarVidPalettize = CVar{
+var CVarVidPalettize = cvar.CVar{
 	Name:      "vid_palettize",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeBool,
 }
-var CVarVidFilter = CVar{
+var CVarVidFilter = cvar.CVar{
 	Name:      "vid_filter",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeBool,
 }
-var CVarVidAnisotropic = CVar{
+var CVarVidAnisotropic = cvar.CVar{
 	Name:      "vid_anisotropic",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeBool,
 }
-var CVarVidFSAA = CVar{
+var CVarVidFSAA = cvar.CVar{
 	Name:      "vid_fsaa",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeEnum,
+	Values:    []string{"0", "2", "4", "8", "16"},
 }
-var CVarVidFSAAMode = CVar{
+var CVarVidFSAAMode = cvar.CVar{
 	Name:      "vid_fsaamode",
 	StringVal: "0",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeBool,
 }
-var CVarVidGamma = CVar{
+var CVarVidGamma = cvar.CVar{
 	Name:      "gamma",
 	StringVal: "0.9",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeFloat,
+	Min:       0.1,
+	Max:       4,
 }
-var CVarVidContrast = CVar{
+var CVarVidContrast = cvar.CVar{
 	Name:      "contrast",
 	StringVal: "1.4",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeFloat,
+	Min:       1,
+	Max:       2,
 }
-var CVarRenderUsesOps = CVar{
+var CVarRenderUsesOps = cvar.CVar{
 	Name:      "r_usesops",
 	StringVal: "1",
-	Flags:     CVarFlagArchive,
+	Flags:     cvar.FlagArchive,
+	Type:      cvar.TypeBool,
 }
 
 type VideoMode struct {
@@ -294,15 +314,15 @@ func (v *SDLVideo) SetMode(width, height, refreshRate int, fullScreen bool) {
 	// TODO: SCR_UpdateRelativeScale()
 }
 
-func (v *SDLVideo) CVarChanged(cvar *CVar) {
+func (v *SDLVideo) CVarChanged(_ *cvar.CVar) {
 	v.changed = true
 }
 
-func (v *SDLVideo) CVarFilterChanged(cvar *CVar) {
+func (v *SDLVideo) CVarFilterChanged(_ *cvar.CVar) {
 	// TODO: R_InitSamplers()
 }
 
-//func (v *SDLVideo) CVarFSAAChanged(cvar *CVar) {
+//func (v *SDLVideo) CVarFSAAChanged(_ *cvar.CVar) {
 //	v.Restart(false)
 //}
 
@@ -353,7 +373,7 @@ func (v *SDLVideo) CmdDescribeCurrentMode() {
 		if v.GetFullscreen() {
 			fullscreen = "fullscreen"
 		}
-		log.Printf("%dx%dx%d %dHz %s\n", v.GetCurrentWidth(), v.GetCurrentHeight(), v.GetCurrentBPP(), v.GetCurrentRefreshRate(), fullscreen)
+		Console.Printf("%dx%dx%d %dHz %s\n", v.GetCurrentWidth(), v.GetCurrentHeight(), v.GetCurrentBPP(), v.GetCurrentRefreshRate(), fullscreen)
 	}
 }
 
@@ -362,14 +382,14 @@ func (v *SDLVideo) CmdDescribeModes() {
 
 	for _, mode := range v.modes {
 		if lastWidth != mode.Width || lastHeight != mode.Height {
-			log.Printf("   %d x %d : %d\n", mode.Width, mode.Height, mode.RefreshRate)
+			Console.Printf("   %d x %d : %d\n", mode.Width, mode.Height, mode.RefreshRate)
 			lastWidth = mode.Width
 			lastHeight = mode.Height
 			count++
 		}
 	}
 
-	log.Printf("%d modes\n", count)
+	Console.Printf("%d modes\n", count)
 }
 
 //func (v *SDLVideo) initModeList() {
@@ -403,23 +423,23 @@ func (v *SDLVideo) CmdDescribeModes() {
 //	CVars.Register(&CVarVidPalettize)
 //	InitDebug()
 //
-//	CVars.SetCallback(&CVarVidFullScreen, v.CVarChanged)
-//	CVars.SetCallback(&CVarVidWidth, v.CVarChanged)
-//	CVars.SetCallback(&CVarVidHeight, v.CVarChanged)
-//	CVars.SetCallback(&CVarVidRefreshRate, v.CVarChanged)
-//	CVars.SetCallback(&CVarVidFilter, v.CVarFilterChanged)
-//	CVars.SetCallback(&CVarVidAnisotropic, v.CVarFilterChanged)
-//	CVars.SetCallback(&CVarVidFSAAMode, v.CVarFSAAChanged)
-//	CVars.SetCallback(&CVarVidFSAA, v.CVarFSAAChanged)
-//	CVars.SetCallback(&CVarVidVSync, v.CVarChanged)
-//	CVars.SetCallback(&CVarVidDesktopFullscreen, v.CVarChanged)
-//	CVars.SetCallback(&CVarVidBorderless, v.CVarChanged)
-//
-//	Cmds.Add("vid_unlock", v.CmdUnlock, CmdSourceCommand)
-//	Cmds.Add("vid_restart", v.CmdRestart, CmdSourceCommand)
-//	Cmds.Add("vid_test", v.CmdTest, CmdSourceCommand)
-//	Cmds.Add("vid_describecurrentmode", v.CmdDescribeCurrentMode, CmdSourceCommand)
-//	Cmds.Add("vid_describemodes", v.CmdDescribeModes, CmdSourceCommand)
+//	CVars.Subscribe(&CVarVidFullScreen, v.CVarChanged)
+//	CVars.Subscribe(&CVarVidWidth, v.CVarChanged)
+//	CVars.Subscribe(&CVarVidHeight, v.CVarChanged)
+//	CVars.Subscribe(&CVarVidRefreshRate, v.CVarChanged)
+//	CVars.Subscribe(&CVarVidFilter, v.CVarFilterChanged)
+//	CVars.Subscribe(&CVarVidAnisotropic, v.CVarFilterChanged)
+//	CVars.Subscribe(&CVarVidFSAAMode, v.CVarFSAAChanged)
+//	CVars.Subscribe(&CVarVidFSAA, v.CVarFSAAChanged)
+//	CVars.Subscribe(&CVarVidVSync, v.CVarChanged)
+//	CVars.Subscribe(&CVarVidDesktopFullscreen, v.CVarChanged)
+//	CVars.Subscribe(&CVarVidBorderless, v.CVarChanged)
+//
+//	Cmds.Add("vid_unlock", v.CmdUnlock, command.SourceCommand)
+//	Cmds.Add("vid_restart", v.CmdRestart, command.SourceCommand)
+//	Cmds.Add("vid_test", v.CmdTest, command.SourceCommand)
+//	Cmds.Add("vid_describecurrentmode", v.CmdDescribeCurrentMode, command.SourceCommand)
+//	Cmds.Add("vid_describemodes", v.CmdDescribeModes, command.SourceCommand)
 //
 //	// TODO: CreatePaletteOctree
 //
@@ -544,6 +564,8 @@ func (v *SDLVideo) CmdDescribeModes() {
 //
 //	v.SynchronizedEndRenderingTask()
 //
+//	CVars.CommitLatched(cvar.LatchVideo)
+//
 //	width := int(CVarVidWidth.Value)
 //	height := int(CVarVidHeight.Value)
 //	refreshRate := int(CVarVidRefreshRate.Value)
@@ -1,5 +1,17 @@
 package main
 
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/vkngwrapper/quake/command"
+	"github.com/vkngwrapper/quake/cvar"
+	"github.com/vkngwrapper/quake/model"
+)
+
 type QuakeParams struct {
 	baseDir string
 	userDir string
@@ -13,3 +25,198 @@ var HostInitialized bool
 var MultiUser bool
 var IsDedicated bool
 var FitzMode bool
+
+// UserDirPath returns the path of a file in the directory used for user-writable data
+func UserDirPath(fileName string) string {
+	dir := HostParams.userDir
+	if MultiUser {
+		dir = sdl.GetPrefPath("", "vkngQuake")
+	} else if dir == "" {
+		dir = HostParams.baseDir
+	}
+
+	return path.Join(dir, fileName)
+}
+
+func HostInitCommands() {
+	Cmds.Add("host_writeconfig", CmdHostWriteConfig, command.SourceCommand)
+	Cmds.Add("quit", CmdHostQuit, command.SourceCommand)
+	Cmds.Add("map", CmdHostMap, command.SourceCommand)
+}
+
+// HostInit sets up the engine's subsystems, in the same order as vkQuake's Host_Init
+func HostInit() {
+	CmdInit()
+	CVars.Init()
+	InitCommon()
+	FileSystemInit()
+	HostInitCommands()
+	InitDebug()
+	Keys.Init()
+	Console.Init()
+	History.Init()
+	ConsoleLine.Init()
+	if IsDedicated {
+		DedicatedConsole.Init()
+	}
+
+	// Registered last, so that they pick up every cvar registered before them
+	ServerInfo.Init()
+	UserInfo.Init()
+
+	HostInitialized = true
+}
+
+// HostFrame runs one frame of the engine: reading console input, then running the commands it
+// queued
+func HostFrame() {
+	if IsDedicated {
+		DedicatedConsole.Poll()
+	} else {
+		SendKeyEvents()
+	}
+
+	Cmds.Execute()
+}
+
+// ConfigFilePath returns where the config is written, matching where OpenConfig and
+// FileSystem.OpenFile look for config.cfg
+func ConfigFilePath() string {
+	if MultiUser {
+		return path.Join(sdl.GetPrefPath("", "vkngQuake"), "config.cfg")
+	}
+
+	return path.Join(Files.GameDir(), "vkQuake.cfg")
+}
+
+// HostWriteConfiguration writes key bindings, aliases and archived cvars to the config file
+func HostWriteConfiguration() {
+	// dedicated servers initialize the host but don't parse and set the config.cfg cvars
+	if !HostInitialized || IsDedicated || HostParams.errState != 0 {
+		return
+	}
+
+	err := writeConfigFile(ConfigFilePath())
+	if err != nil {
+		Console.Printf("Couldn't write %s: %s\n", path.Base(ConfigFilePath()), err)
+	}
+}
+
+// writeConfigFile writes to a temporary file and renames it over the config, so an
+// interrupted write can't leave a truncated config behind
+func writeConfigFile(configPath string) error {
+	file, err := os.CreateTemp(path.Dir(configPath), path.Base(configPath)+".tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	_ = file.Chmod(0644)
+
+	writer := bufio.NewWriter(file)
+	err = Keys.WriteBindings(writer)
+	if err == nil {
+		err = Cmds.WriteAliases(writer)
+	}
+	if err == nil {
+		err = CVars.WriteVariables(writer)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tempPath, configPath)
+	}
+
+	if err != nil {
+		_ = os.Remove(tempPath)
+	}
+	return err
+}
+
+func HostShutdown() {
+	HostWriteConfiguration()
+
+	err := History.Save()
+	if err != nil {
+		Console.Printf("Couldn't write %s: %s\n", HistoryFileName, err)
+	}
+}
+
+func CmdHostWriteConfig() {
+	HostWriteConfiguration()
+}
+
+func CmdHostQuit() {
+	HostShutdown()
+	os.Exit(0)
+}
+
+// CmdHostMap starts a new game on the named map
+func CmdHostMap() {
+	if Cmds.ArgCount() < 2 {
+		Console.Println("map <levelname>: start a new level")
+		return
+	}
+
+	mapName := strings.TrimSuffix(Cmds.Arg(1), ".bsp")
+	err := HostSpawnServer(mapName)
+	if err != nil {
+		Console.Printf("Couldn't spawn server %s: %s\n", mapName, err)
+	}
+}
+
+// HostSpawnServer applies pending changes to server cvars, then loads the map
+func HostSpawnServer(mapName string) error {
+	CVars.CommitLatched(cvar.LatchServer)
+
+	return HostLoadWorld(mapName)
+}
+
+// HostLoadWorld applies pending changes to map cvars, then loads the map's world model
+func HostLoadWorld(mapName string) error {
+	CVars.CommitLatched(cvar.LatchMap)
+
+	models, err := model.NewLoader(Console, Files).LoadBrushModel(path.Join("maps", mapName+".bsp"))
+	if err != nil {
+		return err
+	}
+
+	lightMaps := &model.LightMapAllocator{}
+	err = models[0].BuildSurfaces(lightMaps)
+	if err != nil {
+		return err
+	}
+
+	RenderData.WorldModel = models[0]
+	RenderData.LightMaps = lightMaps
+	return nil
+}
+
+// engineHost gives the command and cvar libraries access to engine state
+type engineHost struct{}
+
+func (engineHost) Initialized() bool {
+	return HostInitialized
+}
+
+func (engineHost) WarnCommands() bool {
+	return CVarClWarncmd.Value != 0
+}
+
+func (engineHost) CommandLine() string {
+	return CVarCmdline.StringVal
+}
+
+func (engineHost) LoadScript(fileName string) ([]byte, bool) {
+	return LoadScript(fileName)
+}
+
+func (engineHost) UserDirPath(fileName string) string {
+	return UserDirPath(fileName)
+}
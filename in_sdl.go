@@ -0,0 +1,39 @@
+package main
+
+import "github.com/veandco/go-sdl2/sdl"
+
+// SendKeyEvents reads the input events SDL has queued and passes them on to the key bindings.
+// It should be run once per host frame.
+func SendKeyEvents() {
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		switch e := event.(type) {
+		case *sdl.KeyboardEvent:
+			Keys.Event(KeyFromKeycode(e.Keysym.Sym), e.State == sdl.PRESSED)
+		case *sdl.TextInputEvent:
+			if Keys.Dest() == KeyDestConsole {
+				ConsoleLine.InsertText(e.GetText())
+			}
+		case *sdl.MouseButtonEvent:
+			key, ok := KeyFromMouseButton(e.Button)
+			if ok {
+				Keys.Event(key, e.State == sdl.PRESSED)
+			}
+		case *sdl.MouseWheelEvent:
+			// The wheel has no release, so each step is a press and release
+			if e.Y > 0 {
+				Keys.Event(KeyMouseWheelUp, true)
+				Keys.Event(KeyMouseWheelUp, false)
+			} else if e.Y < 0 {
+				Keys.Event(KeyMouseWheelDown, true)
+				Keys.Event(KeyMouseWheelDown, false)
+			}
+		case *sdl.ControllerButtonEvent:
+			key, ok := KeyFromControllerButton(sdl.GameControllerButton(e.Button))
+			if ok {
+				Keys.Event(key, e.State == sdl.PRESSED)
+			}
+		case *sdl.QuitEvent:
+			Cmds.AddText("quit\n")
+		}
+	}
+}
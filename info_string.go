@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/vkngwrapper/quake/command"
+	"github.com/vkngwrapper/quake/cvar"
+)
+
+// CVarInfoString is an info string kept up to date with every cvar carrying its flag
+type CVarInfoString struct {
+	*cvar.InfoString
+
+	name string
+	flag cvar.Flags
+}
+
+var ServerInfo = &CVarInfoString{
+	InfoString: cvar.NewInfoString(cvar.MaxServerInfoString),
+	name:       "serverinfo",
+	flag:       cvar.FlagServerInfo,
+}
+
+var UserInfo = &CVarInfoString{
+	InfoString: cvar.NewInfoString(cvar.MaxInfoString),
+	name:       "userinfo",
+	flag:       cvar.FlagUserInfo,
+}
+
+func (i *CVarInfoString) Init() {
+	Cmds.Add(i.name, i.CmdPrint, command.SourceCommand)
+
+	for _, v := range CVars.All() {
+		i.cvarChanged(v)
+	}
+	CVars.SubscribeAll(i.cvarChanged)
+}
+
+func (i *CVarInfoString) cvarChanged(v *cvar.CVar) {
+	if v.Flags&i.flag == 0 {
+		return
+	}
+
+	err := i.Set(v.Name, v.StringVal)
+	if err != nil {
+		Console.Printf("Can't add %s to %s: %s\n", v.Name, i.name, err)
+	}
+}
+
+func (i *CVarInfoString) CmdPrint() {
+	pairs := i.Pairs()
+	if len(pairs) == 0 {
+		Console.Printf("%s is empty\n", i.name)
+		return
+	}
+
+	for _, pair := range pairs {
+		Console.Printf("%-20s %s\n", pair.Key, pair.Value)
+	}
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/vkngwrapper/quake/command"
+)
+
+// Key identifies a bindable input.  Keyboard keys use their SDL keycode, mouse and gamepad
+// buttons use values from keyVirtualBase up.  SDL keycodes are either characters, which are far
+// below keyVirtualBase, or scancodes with bit 30 set, which are above every virtual key, so the
+// two never collide.
+type Key int32
+
+const keyVirtualBase Key = 1 << 29
+
+const (
+	KeyMouse1 Key = keyVirtualBase + iota
+	KeyMouse2
+	KeyMouse3
+	KeyMouse4
+	KeyMouse5
+	KeyMouseWheelUp
+	KeyMouseWheelDown
+
+	KeyLThumb
+	KeyRThumb
+	KeyLShoulder
+	KeyRShoulder
+	KeyLTrigger
+	KeyRTrigger
+	KeyAButton
+	KeyBButton
+	KeyXButton
+	KeyYButton
+	KeyStart
+	KeyBack
+)
+
+type KeyDest int
+
+const (
+	KeyDestGame KeyDest = iota
+	KeyDestConsole
+	KeyDestMenu
+	KeyDestCount
+)
+
+const MaxKeyBindingLength int = 1024
+
+// KeyLayerHandler receives key events while its layer is the key destination
+type KeyLayerHandler func(key Key, down bool)
+
+type keyName struct {
+	name string
+	key  Key
+}
+
+var keyNames = []keyName{
+	{"TAB", sdl.K_TAB},
+	{"ENTER", sdl.K_RETURN},
+	{"ESCAPE", sdl.K_ESCAPE},
+	{"SPACE", sdl.K_SPACE},
+	{"BACKSPACE", sdl.K_BACKSPACE},
+	{"UPARROW", sdl.K_UP},
+	{"DOWNARROW", sdl.K_DOWN},
+	{"LEFTARROW", sdl.K_LEFT},
+	{"RIGHTARROW", sdl.K_RIGHT},
+
+	{"ALT", sdl.K_LALT},
+	{"CTRL", sdl.K_LCTRL},
+	{"SHIFT", sdl.K_LSHIFT},
+	{"COMMAND", sdl.K_LGUI},
+
+	{"F1", sdl.K_F1},
+	{"F2", sdl.K_F2},
+	{"F3", sdl.K_F3},
+	{"F4", sdl.K_F4},
+	{"F5", sdl.K_F5},
+	{"F6", sdl.K_F6},
+	{"F7", sdl.K_F7},
+	{"F8", sdl.K_F8},
+	{"F9", sdl.K_F9},
+	{"F10", sdl.K_F10},
+	{"F11", sdl.K_F11},
+	{"F12", sdl.K_F12},
+
+	{"INS", sdl.K_INSERT},
+	{"DEL", sdl.K_DELETE},
+	{"PGDN", sdl.K_PAGEDOWN},
+	{"PGUP", sdl.K_PAGEUP},
+	{"HOME", sdl.K_HOME},
+	{"END", sdl.K_END},
+	{"PAUSE", sdl.K_PAUSE},
+	{"PRINTSCREEN", sdl.K_PRINTSCREEN},
+
+	{"KP_NUMLOCK", sdl.K_NUMLOCKCLEAR},
+	{"KP_SLASH", sdl.K_KP_DIVIDE},
+	{"KP_STAR", sdl.K_KP_MULTIPLY},
+	{"KP_MINUS", sdl.K_KP_MINUS},
+	{"KP_HOME", sdl.K_KP_7},
+	{"KP_UPARROW", sdl.K_KP_8},
+	{"KP_PGUP", sdl.K_KP_9},
+	{"KP_PLUS", sdl.K_KP_PLUS},
+	{"KP_LEFTARROW", sdl.K_KP_4},
+	{"KP_5", sdl.K_KP_5},
+	{"KP_RIGHTARROW", sdl.K_KP_6},
+	{"KP_END", sdl.K_KP_1},
+	{"KP_DOWNARROW", sdl.K_KP_2},
+	{"KP_PGDN", sdl.K_KP_3},
+	{"KP_ENTER", sdl.K_KP_ENTER},
+	{"KP_INS", sdl.K_KP_0},
+	{"KP_DEL", sdl.K_KP_PERIOD},
+
+	{"MOUSE1", KeyMouse1},
+	{"MOUSE2", KeyMouse2},
+	{"MOUSE3", KeyMouse3},
+	{"MOUSE4", KeyMouse4},
+	{"MOUSE5", KeyMouse5},
+	{"MWHEELUP", KeyMouseWheelUp},
+	{"MWHEELDOWN", KeyMouseWheelDown},
+
+	{"LTHUMB", KeyLThumb},
+	{"RTHUMB", KeyRThumb},
+	{"LSHOULDER", KeyLShoulder},
+	{"RSHOULDER", KeyRShoulder},
+	{"LTRIGGER", KeyLTrigger},
+	{"RTRIGGER", KeyRTrigger},
+	{"ABUTTON", KeyAButton},
+	{"BBUTTON", KeyBButton},
+	{"XBUTTON", KeyXButton},
+	{"YBUTTON", KeyYButton},
+	{"START", KeyStart},
+	{"BACK", KeyBack},
+
+	// Semicolons can't be written in bind commands without being treated as a command separator,
+	// and quotes can't be written inside the quoted key name
+	{"SEMICOLON", sdl.K_SEMICOLON},
+	{"DOUBLEQUOTE", sdl.K_QUOTEDBL},
+}
+
+// keyCodePrefix names keys that have no name of their own by their keycode
+const keyCodePrefix = "KEYCODE_"
+
+// KeyFromKeycode converts an SDL keycode to a Key, folding the right hand modifier keys
+// onto the left hand ones
+func KeyFromKeycode(keycode sdl.Keycode) Key {
+	switch keycode {
+	case sdl.K_RALT:
+		return sdl.K_LALT
+	case sdl.K_RCTRL:
+		return sdl.K_LCTRL
+	case sdl.K_RSHIFT:
+		return sdl.K_LSHIFT
+	case sdl.K_RGUI:
+		return sdl.K_LGUI
+	}
+
+	return Key(keycode)
+}
+
+// KeyFromMouseButton converts an SDL mouse button index to a Key
+func KeyFromMouseButton(button uint8) (Key, bool) {
+	switch button {
+	case sdl.BUTTON_LEFT:
+		return KeyMouse1, true
+	case sdl.BUTTON_RIGHT:
+		return KeyMouse2, true
+	case sdl.BUTTON_MIDDLE:
+		return KeyMouse3, true
+	case sdl.BUTTON_X1:
+		return KeyMouse4, true
+	case sdl.BUTTON_X2:
+		return KeyMouse5, true
+	}
+
+	return 0, false
+}
+
+// KeyFromControllerButton converts an SDL game controller button to a Key.  The d-pad acts
+// as the arrow keys.
+func KeyFromControllerButton(button sdl.GameControllerButton) (Key, bool) {
+	switch button {
+	case sdl.CONTROLLER_BUTTON_A:
+		return KeyAButton, true
+	case sdl.CONTROLLER_BUTTON_B:
+		return KeyBButton, true
+	case sdl.CONTROLLER_BUTTON_X:
+		return KeyXButton, true
+	case sdl.CONTROLLER_BUTTON_Y:
+		return KeyYButton, true
+	case sdl.CONTROLLER_BUTTON_BACK:
+		return KeyBack, true
+	case sdl.CONTROLLER_BUTTON_START:
+		return KeyStart, true
+	case sdl.CONTROLLER_BUTTON_LEFTSTICK:
+		return KeyLThumb, true
+	case sdl.CONTROLLER_BUTTON_RIGHTSTICK:
+		return KeyRThumb, true
+	case sdl.CONTROLLER_BUTTON_LEFTSHOULDER:
+		return KeyLShoulder, true
+	case sdl.CONTROLLER_BUTTON_RIGHTSHOULDER:
+		return KeyRShoulder, true
+	case sdl.CONTROLLER_BUTTON_DPAD_UP:
+		return sdl.K_UP, true
+	case sdl.CONTROLLER_BUTTON_DPAD_DOWN:
+		return sdl.K_DOWN, true
+	case sdl.CONTROLLER_BUTTON_DPAD_LEFT:
+		return sdl.K_LEFT, true
+	case sdl.CONTROLLER_BUTTON_DPAD_RIGHT:
+		return sdl.K_RIGHT, true
+	}
+
+	return 0, false
+}
+
+// KeyForName converts a key name as used by the bind commands to a Key.  Single characters
+// are the key that types them, anything else is looked up in the key name table.
+func KeyForName(name string) (Key, bool) {
+	nameRunes := []rune(name)
+	if len(nameRunes) == 1 {
+		return Key(unicode.ToLower(nameRunes[0])), true
+	}
+
+	for _, entry := range keyNames {
+		if strings.EqualFold(entry.name, name) {
+			return entry.key, true
+		}
+	}
+
+	if len(name) > len(keyCodePrefix) && strings.EqualFold(name[:len(keyCodePrefix)], keyCodePrefix) {
+		keyCode, err := strconv.ParseInt(name[len(keyCodePrefix):], 10, 32)
+		if err == nil && keyCode > 0 {
+			return Key(keyCode), true
+		}
+	}
+
+	return 0, false
+}
+
+// KeyName converts a Key to the name the bind commands accept for it.  Keys that type a
+// character are named by it, which includes the letters of non-English layouts, and any other
+// key without a name in the table is named by its keycode.
+func KeyName(key Key) string {
+	for _, entry := range keyNames {
+		if entry.key == key {
+			return entry.name
+		}
+	}
+
+	if key > ' ' && key <= unicode.MaxRune && key != '"' && unicode.IsPrint(rune(key)) &&
+		unicode.ToLower(rune(key)) == rune(key) {
+		return string(rune(key))
+	}
+
+	return fmt.Sprintf("%s%d", keyCodePrefix, key)
+}
+
+type KeyBindings struct {
+	bindings map[Key]string
+	// The button command run when each held key went down, so that the matching release command
+	// is still run if the binding or the key destination changes while the key is held
+	pressed  map[Key]string
+	dest     KeyDest
+	handlers [KeyDestCount]KeyLayerHandler
+}
+
+var Keys = &KeyBindings{}
+
+func (k *KeyBindings) Init() {
+	k.bindings = make(map[Key]string)
+	k.pressed = make(map[Key]string)
+
+	Cmds.Add("bind", k.CmdBind, command.SourceCommand)
+	Cmds.Add("unbind", k.CmdUnbind, command.SourceCommand)
+	Cmds.Add("unbindall", k.CmdUnbindAll, command.SourceCommand)
+	Cmds.Add("bindlist", k.CmdBindList, command.SourceCommand)
+}
+
+func (k *KeyBindings) SetBinding(key Key, binding string) {
+	if binding == "" {
+		delete(k.bindings, key)
+		return
+	}
+
+	k.bindings[key] = binding
+}
+
+func (k *KeyBindings) Binding(key Key) string {
+	return k.bindings[key]
+}
+
+// Dest returns the layer that currently receives key events
+func (k *KeyBindings) Dest() KeyDest {
+	return k.dest
+}
+
+func (k *KeyBindings) SetDest(dest KeyDest) {
+	k.dest = dest
+}
+
+// SetLayerHandler installs the handler that receives key events while the layer is the key
+// destination.  Layers without a handler fall through to the game bindings.
+func (k *KeyBindings) SetLayerHandler(dest KeyDest, handler KeyLayerHandler) {
+	k.handlers[dest] = handler
+}
+
+// Event dispatches a key press or release to the current key destination
+func (k *KeyBindings) Event(key Key, down bool) {
+	if !down {
+		// Releases always run the matching button command, even when the key went down in the
+		// game and was released in the console or menu, so actions don't get stuck on
+		pressedCmd, wasPressed := k.pressed[key]
+		if wasPressed {
+			delete(k.pressed, key)
+			Cmds.AddText(fmt.Sprintf("-%s %d\n", pressedCmd[1:], key))
+		}
+	}
+
+	if down && key == sdl.K_BACKQUOTE {
+		// The console key can't be bound, so that the console can always be reached
+		ConsoleLine.Toggle()
+		return
+	}
+
+	handler := k.handlers[k.dest]
+	if k.dest != KeyDestGame && handler != nil {
+		handler(key, down)
+		return
+	}
+
+	if !down {
+		return
+	}
+
+	binding := k.bindings[key]
+	if binding == "" {
+		return
+	}
+
+	if binding[0] == '+' {
+		if _, alreadyPressed := k.pressed[key]; alreadyPressed {
+			// Key repeat
+			return
+		}
+
+		// Button commands include the key number as a parameter, so multiple keys bound to the
+		// same button don't release each other
+		k.pressed[key] = binding
+		Cmds.AddText(fmt.Sprintf("%s %d\n", binding, key))
+		return
+	}
+
+	Cmds.AddText(binding + "\n")
+}
+
+// WriteBindings writes bind commands that will restore the current bindings
+func (k *KeyBindings) WriteBindings(writer io.Writer) error {
+	_, err := fmt.Fprintln(writer, "unbindall")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range k.sortedKeys() {
+		_, err = fmt.Fprintf(writer, "bind \"%s\" \"%s\"\n", KeyName(key), k.bindings[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (k *KeyBindings) sortedKeys() []Key {
+	keys := make([]Key, 0, len(k.bindings))
+	for key := range k.bindings {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+func (k *KeyBindings) CmdBind() {
+	argCount := Cmds.ArgCount()
+
+	if argCount < 2 {
+		Console.Println("bind <key> [command] : attach a command to a key")
+		return
+	}
+
+	key, ok := KeyForName(Cmds.Arg(1))
+	if !ok {
+		Console.Printf("\"%s\" isn't a valid key\n", Cmds.Arg(1))
+		return
+	}
+
+	if argCount == 2 {
+		binding := k.bindings[key]
+		if binding != "" {
+			Console.Printf("\"%s\" = \"%s\"\n", Cmds.Arg(1), binding)
+		} else {
+			Console.Printf("\"%s\" is not bound\n", Cmds.Arg(1))
+		}
+		return
+	}
+
+	// Unquoted commands arrive as separate arguments
+	args := make([]string, 0, argCount-2)
+	for i := 2; i < argCount; i++ {
+		args = append(args, Cmds.Arg(i))
+	}
+
+	binding := strings.Join(args, " ")
+	if len(binding) >= MaxKeyBindingLength {
+		Console.Println("Binding is too long")
+		return
+	}
+
+	k.SetBinding(key, binding)
+}
+
+func (k *KeyBindings) CmdUnbind() {
+	if Cmds.ArgCount() != 2 {
+		Console.Println("unbind <key> : remove commands from a key")
+		return
+	}
+
+	key, ok := KeyForName(Cmds.Arg(1))
+	if !ok {
+		Console.Printf("\"%s\" isn't a valid key\n", Cmds.Arg(1))
+		return
+	}
+
+	k.SetBinding(key, "")
+}
+
+func (k *KeyBindings) CmdUnbindAll() {
+	clear(k.bindings)
+}
+
+func (k *KeyBindings) CmdBindList() {
+	keys := k.sortedKeys()
+	for _, key := range keys {
+		Console.Printf("   %s \"%s\"\n", KeyName(key), k.bindings[key])
+	}
+
+	Console.Printf("%d bindings\n", len(keys))
+}
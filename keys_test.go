@@ -0,0 +1,71 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestKeyNames(t *testing.T) {
+	tests := []struct {
+		key  Key
+		name string
+	}{
+		{key: 'a', name: "a"},
+		{key: 'ä', name: "ä"},
+		{key: '\'', name: "'"},
+		{key: '"', name: "DOUBLEQUOTE"},
+		{key: ';', name: "SEMICOLON"},
+		{key: sdl.K_F1, name: "F1"},
+		{key: KeyMouse1, name: "MOUSE1"},
+		{key: 1<<30 | 0x7f, name: "KEYCODE_1073741951"},
+		{key: 'Ä', name: "KEYCODE_196"},
+	}
+
+	for _, test := range tests {
+		if name := KeyName(test.key); name != test.name {
+			t.Errorf("key %d: got name %q, want %q", test.key, name, test.name)
+		}
+		if key, ok := KeyForName(test.name); !ok || key != test.key {
+			t.Errorf("name %q: got key %d, want %d", test.name, key, test.key)
+		}
+	}
+
+	// Key names are case insensitive, and upper case letters are the keys that type them
+	if key, _ := KeyForName("Ä"); key != 'ä' {
+		t.Errorf("got key %d for Ä", key)
+	}
+	if key, _ := KeyForName("keycode_1073741951"); key != 1<<30|0x7f {
+		t.Errorf("got key %d for keycode_1073741951", key)
+	}
+}
+
+func TestWriteBindingsRoundTrip(t *testing.T) {
+	written := &KeyBindings{bindings: map[Key]string{
+		'a':             "+moveleft",
+		'ä':             "impulse 7",
+		'"':             "say hello",
+		';':             "echo semicolon; wait",
+		sdl.K_F1:        "help",
+		KeyMouse1:       "+attack",
+		1<<30 | 0x7f:    "toggleconsole",
+		KeyMouseWheelUp: "impulse 10",
+	}}
+
+	var config strings.Builder
+	err := written.WriteBindings(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Keys.Init()
+	Keys.SetBinding('z', "stale binding")
+	Cmds.AddText(config.String())
+	Cmds.Execute()
+
+	if !maps.Equal(Keys.bindings, written.bindings) {
+		t.Errorf("got bindings %v, want %v from config:\n%s", Keys.bindings, written.bindings, config.String())
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"time"
 )
 
 func main() {
@@ -15,4 +16,12 @@ func main() {
 	CmdLine.Init(os.Args)
 	IsDedicated = CmdLine.CheckParam("-dedicated") > 0
 
+	HostInit()
+
+	for {
+		HostFrame()
+
+		// Don't spin when there's nothing to do
+		time.Sleep(time.Millisecond)
+	}
 }
@@ -0,0 +1,907 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/vkngwrapper/math"
+)
+
+// AnimCycle is the number of tenths of a second each frame of an animated texture is shown for
+const AnimCycle int = 2
+
+var hullSizes = [MaxMapHulls][2]math.Vec3[float32]{
+	{},
+	{{X: -16, Y: -16, Z: -24}, {X: 16, Y: 16, Z: 32}},
+	{{X: -32, Y: -32, Z: -24}, {X: 32, Y: 32, Z: 64}},
+	{},
+}
+
+// ParseBrushModel builds a model for each of the submodels in a bsp file.  The first is the world
+// and is named after the file, the rest are named *1, *2 and so on, the way entities refer to them.
+// All of them share the world's brush data.  lit is the contents of the map's .lit file, or nil if
+// it doesn't have one.
+func ParseBrushModel(name string, data []byte, lit []byte) ([]*QModel, error) {
+	header, err := ReadHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	format, ok := FormatForVersion(int(header.Version))
+	if !ok {
+		return nil, fmt.Errorf("%s has wrong version number (%d should be %d, %s or %s)", name, header.Version,
+			BSPVersion29, Format2PSB, FormatBSP2)
+	}
+	if lit != nil {
+		err = ValidateLit(lit, header)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", LitPath(name), err)
+		}
+	}
+
+	mod := &QModel{
+		Name:       name,
+		Type:       ModelBrush,
+		BSPVersion: int(header.Version),
+		NumFrames:  2, // regular and alternate animation
+	}
+
+	mod.BSPX, err = ReadBSPX(data, header)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	loader := brushLoader{mod: mod, data: data, header: header, format: format, lit: lit}
+	for _, load := range []func() error{
+		loader.loadVertices,
+		loader.loadEdges,
+		loader.loadSurfEdges,
+		loader.loadTextures,
+		loader.loadLighting,
+		loader.loadPlanes,
+		loader.loadTexInfo,
+		loader.loadFaces,
+		loader.loadMarkSurfaces,
+		loader.loadVisibility,
+		loader.loadLeafs,
+		loader.loadNodes,
+		loader.loadClipNodes,
+		loader.loadEntities,
+		loader.loadSubmodels,
+	} {
+		err = load()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	loader.makeHull0()
+
+	models := make([]*QModel, len(mod.Submodels))
+	for i := range mod.Submodels {
+		sub := mod
+		if i > 0 {
+			copied := *mod
+			copied.Name = fmt.Sprintf("*%d", i)
+			sub = &copied
+		}
+		sub.setSubmodel(&mod.Submodels[i])
+		models[i] = sub
+	}
+
+	return models, nil
+}
+
+// Format returns the bsp format that a brush model was loaded from
+func (m *QModel) Format() Format {
+	format, _ := FormatForVersion(m.BSPVersion)
+	return format
+}
+
+// ReadHeader reads the header of a bsp file and checks that its lumps are inside the file
+func ReadHeader(data []byte) (*Header, error) {
+	var header Header
+	err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header)
+	if err != nil {
+		return nil, fmt.Errorf("file is too short for a bsp header (%d bytes)", len(data))
+	}
+
+	for i, lump := range header.Lumps {
+		if lump.FileOfs < 0 || lump.FileLen < 0 || int64(lump.FileOfs)+int64(lump.FileLen) > int64(len(data)) {
+			return nil, fmt.Errorf("%s lump (offset %d, length %d) is outside of the file (%d bytes)",
+				LumpNames[i], lump.FileOfs, lump.FileLen, len(data))
+		}
+	}
+
+	return &header, nil
+}
+
+func (m *QModel) setSubmodel(bm *DModel) {
+	m.Hulls[0].FirstClipNode = bm.HeadNode[0]
+	for j := 1; j < MaxMapHulls; j++ {
+		m.Hulls[j].FirstClipNode = bm.HeadNode[j]
+		m.Hulls[j].LastClipNode = len(m.ClipNodes) - 1
+	}
+
+	m.FirstModelSurface = bm.FirstFace
+	m.NumModelSurfaces = bm.NumFaces
+	m.NumLeafs = bm.VisLeafs
+
+	m.Mins = math.Vec3[float32]{X: bm.Mins[0], Y: bm.Mins[1], Z: bm.Mins[2]}
+	m.Maxs = math.Vec3[float32]{X: bm.Maxs[0], Y: bm.Maxs[1], Z: bm.Maxs[2]}
+
+	// Bounds for entities that are rotated
+	radius := radiusFromBounds(bm.Mins, bm.Maxs)
+	m.RMaxs = math.Vec3[float32]{X: radius, Y: radius, Z: radius}
+	m.RMins = math.Vec3[float32]{X: -radius, Y: -radius, Z: -radius}
+	m.YMaxs = math.Vec3[float32]{X: radius, Y: radius, Z: bm.Maxs[2]}
+	m.YMins = math.Vec3[float32]{X: -radius, Y: -radius, Z: bm.Mins[2]}
+}
+
+func radiusFromBounds(mins, maxs [3]float32) float32 {
+	var corner math.Vec3[float32]
+	for i, p := range []*float32{&corner.X, &corner.Y, &corner.Z} {
+		*p = max(abs(mins[i]), abs(maxs[i]))
+	}
+
+	return corner.Len()
+}
+
+func abs(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+type brushLoader struct {
+	mod    *QModel
+	data   []byte
+	header *Header
+	format Format
+	lit    []byte
+
+	nodePlanes []int // plane index of each node, for makeHull0
+}
+
+func (l *brushLoader) lump(index int) []byte {
+	lump := l.header.Lumps[index]
+	return l.data[lump.FileOfs : lump.FileOfs+lump.FileLen]
+}
+
+// readLump decodes every record in a lump
+func readLump[T any](l *brushLoader, index int) ([]T, error) {
+	var record T
+	size := binary.Size(record)
+	data := l.lump(index)
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("%s lump has a funny size (%d bytes isn't a multiple of %d)", LumpNames[index], len(data), size)
+	}
+
+	records := make([]T, len(data)/size)
+	err := binary.Read(bytes.NewReader(data), binary.LittleEndian, records)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s lump: %w", LumpNames[index], err)
+	}
+
+	return records, nil
+}
+
+// readConverted decodes every record in a lump and converts them to the widest record format
+func readConverted[T converter[R], R any](l *brushLoader, index int) ([]R, error) {
+	in, err := readLump[T](l, index)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]R, len(in))
+	for i, record := range in {
+		records[i] = record.convert()
+	}
+
+	return records, nil
+}
+
+// unsignedIndex turns a child index from a BSP29 map, which stores them unsigned so that maps can
+// have more than 32767 nodes, back into a negative leaf or contents number.  Indices from
+// threshold up are negative.
+func (l *brushLoader) unsignedIndex(index int32, threshold int) int {
+	if l.format == FormatBSP29 && int(index) >= threshold {
+		return int(index) - 65536
+	}
+
+	return int(index)
+}
+
+// clipNodeContentsStart is where BSP29 clipnode children switch from clipnode indices to
+// contents, which leaves room for maps with more than 32767 clipnodes
+const clipNodeContentsStart = 0xfff0
+
+func (l *brushLoader) loadVertices() error {
+	in, err := readLump[dVertex](l, LumpVertices)
+	if err != nil {
+		return err
+	}
+
+	l.mod.Vertices = make([]MVertex, len(in))
+	for i, v := range in {
+		l.mod.Vertices[i].Position = math.Vec3[float32]{X: v.Point[0], Y: v.Point[1], Z: v.Point[2]}
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadEdges() error {
+	var in []dEdgeBSP2
+	var err error
+	if l.format == FormatBSP29 {
+		in, err = readConverted[dEdge29](l, LumpEdges)
+	} else {
+		in, err = readLump[dEdgeBSP2](l, LumpEdges)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.mod.Edges = make([]MEdge, len(in))
+	for i, e := range in {
+		for j, v := range e.V {
+			if int(v) >= len(l.mod.Vertices) {
+				return fmt.Errorf("edge %d uses vertex %d of %d", i, v, len(l.mod.Vertices))
+			}
+			l.mod.Edges[i].V[j] = uint(v)
+		}
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadSurfEdges() error {
+	in, err := readLump[int32](l, LumpSurfEdges)
+	if err != nil {
+		return err
+	}
+
+	l.mod.SurfEdges = make([]int, len(in))
+	for i, e := range in {
+		edge := int(e)
+		if edge >= len(l.mod.Edges) || -edge >= len(l.mod.Edges) {
+			return fmt.Errorf("surfedge %d uses edge %d of %d", i, edge, len(l.mod.Edges))
+		}
+		l.mod.SurfEdges[i] = edge
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadTextures() error {
+	data := l.lump(LumpTextures)
+	var textures []*Texture
+
+	if len(data) > 0 {
+		reader := bytes.NewReader(data)
+		var count int32
+		err := binary.Read(reader, binary.LittleEndian, &count)
+		if err != nil || count < 0 {
+			return fmt.Errorf("textures lump is too short for its texture count")
+		}
+		if int64(count)*4 > int64(reader.Len()) {
+			return fmt.Errorf("textures lump is too short for %d textures", count)
+		}
+
+		offsets := make([]int32, count)
+		_ = binary.Read(reader, binary.LittleEndian, offsets)
+
+		textures = make([]*Texture, count)
+		for i, offset := range offsets {
+			if offset == -1 {
+				continue
+			}
+
+			texture, err := l.loadTexture(data, int(offset))
+			if err != nil {
+				return fmt.Errorf("texture %d: %w", i, err)
+			}
+			textures[i] = texture
+		}
+	}
+
+	// Stand-ins for textures that are missing from the map, for solid and liquid surfaces
+	textures = append(textures,
+		&Texture{Name: "notexture", Width: 32, Height: 32},
+		&Texture{Name: "notexture2", Width: 32, Height: 32},
+	)
+	l.mod.Textures = textures
+
+	return sequenceAnimations(textures[:len(textures)-2])
+}
+
+func (l *brushLoader) loadTexture(data []byte, offset int) (*Texture, error) {
+	var mipTex dMipTex
+	if offset < 0 || offset+binary.Size(mipTex) > len(data) {
+		return nil, fmt.Errorf("offset %d is outside of the textures lump", offset)
+	}
+	_ = binary.Read(bytes.NewReader(data[offset:]), binary.LittleEndian, &mipTex)
+
+	name := mipTex.Name[:]
+	nullIndex := bytes.IndexByte(name, 0)
+	if nullIndex >= 0 {
+		name = name[:nullIndex]
+	}
+
+	texture := &Texture{
+		Name:         string(name),
+		Width:        uint(mipTex.Width),
+		Height:       uint(mipTex.Height),
+		SourceFile:   l.mod.Name,
+		SourceOffset: int(l.header.Lumps[LumpTextures].FileOfs) + offset + binary.Size(mipTex),
+	}
+	for i, mipOffset := range mipTex.Offsets {
+		texture.Offsets[i] = uint(mipOffset)
+	}
+
+	if mipTex.Width&15 != 0 || mipTex.Height&15 != 0 {
+		return nil, fmt.Errorf("%s is %dx%d, which isn't 16 aligned", texture.Name, mipTex.Width, mipTex.Height)
+	}
+
+	// Each mip level is a quarter the size of the one before it
+	pixels := int64(mipTex.Width) * int64(mipTex.Height) / 64 * 85
+	if int64(offset)+int64(binary.Size(mipTex))+pixels > int64(len(data)) {
+		return nil, fmt.Errorf("%s extends past the end of the textures lump", texture.Name)
+	}
+
+	return texture, nil
+}
+
+// animFrame returns the frame a texture named +<frame><name> is for, and whether it's in the
+// alternate sequence (+a to +j) rather than the regular one (+0 to +9)
+func animFrame(texture *Texture) (frame int, alternate bool, err error) {
+	num := texture.Name[1]
+	if num >= 'a' && num <= 'z' {
+		num -= 'a' - 'A'
+	}
+
+	if num >= '0' && num <= '9' {
+		return int(num - '0'), false, nil
+	}
+	if num >= 'A' && num <= 'J' {
+		return int(num - 'A'), true, nil
+	}
+
+	return 0, false, fmt.Errorf("bad animating texture %s", texture.Name)
+}
+
+// sequenceAnimations links animating textures, which are named +<frame><name>, into their sequences
+func sequenceAnimations(textures []*Texture) error {
+	for i, texture := range textures {
+		if texture == nil || len(texture.Name) < 2 || texture.Name[0] != '+' || texture.AnimNext != nil {
+			continue
+		}
+
+		var anims [10]*Texture
+		var altAnims [10]*Texture
+		var animCount, altCount int
+
+		for _, frameTexture := range textures[i:] {
+			if frameTexture == nil || len(frameTexture.Name) < 2 || frameTexture.Name[0] != '+' ||
+				frameTexture.Name[2:] != texture.Name[2:] {
+				continue
+			}
+
+			frame, alternate, err := animFrame(frameTexture)
+			if err != nil {
+				return err
+			}
+
+			if alternate {
+				altAnims[frame] = frameTexture
+				altCount = max(altCount, frame+1)
+			} else {
+				anims[frame] = frameTexture
+				animCount = max(animCount, frame+1)
+			}
+		}
+
+		err := linkAnimation(anims[:animCount], altAnims[:altCount], texture.Name)
+		if err != nil {
+			return err
+		}
+		err = linkAnimation(altAnims[:altCount], anims[:animCount], texture.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func linkAnimation(frames []*Texture, alternates []*Texture, name string) error {
+	for j, frame := range frames {
+		if frame == nil {
+			return fmt.Errorf("missing frame %d of %s", j, name)
+		}
+
+		frame.AnimTotal = len(frames) * AnimCycle
+		frame.AnimMin = j * AnimCycle
+		frame.AnimMax = (j + 1) * AnimCycle
+		frame.AnimNext = frames[(j+1)%len(frames)]
+		if len(alternates) > 0 {
+			frame.AlternateAnims = alternates[0]
+		}
+	}
+
+	return nil
+}
+
+// DefaultLightMapShift gives lightmaps a sample every 16 world units
+const DefaultLightMapShift int = 4
+
+func (l *brushLoader) loadLighting() error {
+	data := l.lump(LumpLighting)
+
+	if l.lit != nil {
+		l.mod.LightData = bytes.Clone(litSamples(l.lit))
+		return nil
+	}
+
+	// Fall back to lighting from the map itself, in color if the compiler added it
+	rgb := l.mod.BSPX.Lump(BSPXRGBLighting)
+	if rgb != nil && len(rgb) == len(data)*3 {
+		l.mod.LightData = bytes.Clone(rgb)
+		return nil
+	}
+
+	if len(data) > 0 {
+		l.mod.LightData = make([]byte, len(data)*3)
+		for i, sample := range data {
+			l.mod.LightData[i*3] = sample
+			l.mod.LightData[i*3+1] = sample
+			l.mod.LightData[i*3+2] = sample
+		}
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadVisibility() error {
+	data := l.lump(LumpVisibility)
+	if len(data) > 0 {
+		l.mod.VisData = bytes.Clone(data)
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadEntities() error {
+	data := l.lump(LumpEntities)
+	nullIndex := bytes.IndexByte(data, 0)
+	if nullIndex >= 0 {
+		data = data[:nullIndex]
+	}
+	l.mod.Entities = string(data)
+
+	return nil
+}
+
+func (l *brushLoader) loadPlanes() error {
+	in, err := readLump[dPlane](l, LumpPlanes)
+	if err != nil {
+		return err
+	}
+
+	l.mod.Planes = make([]MPlane, len(in))
+	for i, p := range in {
+		plane := &l.mod.Planes[i]
+		plane.Normal = math.Vec3[float32]{X: p.Normal[0], Y: p.Normal[1], Z: p.Normal[2]}
+		plane.Dist = p.Dist
+		plane.Type = byte(p.Type)
+		for j, n := range p.Normal {
+			if n < 0 {
+				plane.SignBits |= 1 << j
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadTexInfo() error {
+	in, err := readLump[dTexInfo](l, LumpTexInfo)
+	if err != nil {
+		return err
+	}
+
+	textureCount := len(l.mod.Textures) - 2
+	l.mod.TexInfo = make([]TexInfo, len(in))
+	for i, t := range in {
+		texInfo := &l.mod.TexInfo[i]
+		texInfo.Vecs = t.Vecs
+		texInfo.Flags = int(t.Flags)
+		texInfo.TexIndex = int(t.MipTex)
+
+		if t.MipTex >= 0 && int(t.MipTex) < textureCount && l.mod.Textures[t.MipTex] != nil {
+			texInfo.Texture = l.mod.Textures[t.MipTex]
+			continue
+		}
+
+		if texInfo.Flags&TexSpecial != 0 {
+			texInfo.Texture = l.mod.Textures[textureCount+1]
+		} else {
+			texInfo.Texture = l.mod.Textures[textureCount]
+		}
+		texInfo.Flags |= TexMissing
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadFaces() error {
+	var in []dFaceBSP2
+	var err error
+	if l.format == FormatBSP29 {
+		in, err = readConverted[dFace29](l, LumpFaces)
+	} else {
+		in, err = readLump[dFaceBSP2](l, LumpFaces)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Lightmaps at other scales have their own offsets and styles, since the face's describe the
+	// standard lightmaps
+	shifts := faceLump[uint8](l.mod.BSPX, BSPXLightMapShift, len(in))
+	offsets := faceLump[int32](l.mod.BSPX, BSPXLightMapOfs, len(in))
+	styles := faceLump[[MaxLightMaps]byte](l.mod.BSPX, BSPXLightMapStyle, len(in))
+	if shifts == nil || offsets == nil || styles == nil {
+		shifts = nil
+	}
+	decoupled := faceLump[dDecoupledLM](l.mod.BSPX, BSPXDecoupledLM, len(in))
+
+	l.mod.Surfaces = make([]MSurface, len(in))
+	for i, f := range in {
+		surf := &l.mod.Surfaces[i]
+		surf.FirstEdge = int(f.FirstEdge)
+		surf.NumEdges = int(f.NumEdges)
+		if surf.FirstEdge < 0 || surf.NumEdges < 0 || surf.FirstEdge+surf.NumEdges > len(l.mod.SurfEdges) {
+			return fmt.Errorf("face %d uses surfedges %d to %d of %d", i, surf.FirstEdge, surf.FirstEdge+surf.NumEdges, len(l.mod.SurfEdges))
+		}
+
+		if f.PlaneNum < 0 || int(f.PlaneNum) >= len(l.mod.Planes) {
+			return fmt.Errorf("face %d uses plane %d of %d", i, f.PlaneNum, len(l.mod.Planes))
+		}
+		surf.Plane = &l.mod.Planes[f.PlaneNum]
+		if f.Side != 0 {
+			surf.Flags |= SurfPlaneBack
+		}
+
+		if f.TexInfo < 0 || int(f.TexInfo) >= len(l.mod.TexInfo) {
+			return fmt.Errorf("face %d uses texinfo %d of %d", i, f.TexInfo, len(l.mod.TexInfo))
+		}
+		surf.TexInfo = &l.mod.TexInfo[f.TexInfo]
+
+		surf.LightMapShift = DefaultLightMapShift
+		surf.Styles = f.Styles
+		lightOfs := f.LightOfs
+		if shifts != nil {
+			surf.LightMapShift = int(shifts[i])
+			surf.Styles = styles[i]
+			lightOfs = offsets[i]
+		}
+		if decoupled != nil {
+			lightMap := decoupled[i]
+			surf.LightMapShift = 0
+			surf.DecoupledLightMap = true
+			surf.LightMapVecs = lightMap.WorldToLM
+			surf.Extents = [2]int16{int16(lightMap.LightMapSize[0]) - 1, int16(lightMap.LightMapSize[1]) - 1}
+			lightOfs = lightMap.LightOfs
+		}
+
+		// Faces with lighting outside of the lump are drawn fullbright rather than failing the load
+		if lightOfs >= 0 && int64(lightOfs)*3 < int64(len(l.mod.LightData)) {
+			surf.Samples = l.mod.LightData[lightOfs*3:]
+		}
+
+		surf.Flags |= surfaceFlags(surf)
+
+		err = l.mod.CalcSurfaceExtents(surf)
+		if err != nil {
+			return fmt.Errorf("face %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// surfaceFlags returns the flags for how a surface should be drawn, based on its texture
+func surfaceFlags(surf *MSurface) int {
+	name := surf.TexInfo.Texture.Name
+
+	switch {
+	case len(name) >= 3 && strings.EqualFold(name[:3], "sky"):
+		return SurfDrawSky | SurfDrawTiled
+	case strings.HasPrefix(name, "*"):
+		flags := SurfDrawTurb
+		if surf.TexInfo.Flags&TexSpecial != 0 {
+			flags |= SurfDrawTiled
+		}
+
+		switch {
+		case strings.HasPrefix(name, "*lava"):
+			flags |= SurfDrawLava
+		case strings.HasPrefix(name, "*slime"):
+			flags |= SurfDrawSlime
+		case strings.HasPrefix(name, "*tele"):
+			flags |= SurfDrawTele
+		default:
+			flags |= SurfDrawWater
+		}
+		return flags
+	case strings.HasPrefix(name, "{"):
+		return SurfDrawFence
+	case surf.TexInfo.Flags&TexMissing != 0:
+		if surf.Samples != nil {
+			return SurfNoTexture
+		}
+		return SurfNoTexture | SurfDrawTiled
+	}
+
+	return 0
+}
+
+func (l *brushLoader) loadMarkSurfaces() error {
+	var in []uint32
+	var err error
+	if l.format == FormatBSP29 {
+		var in16 []uint16
+		in16, err = readLump[uint16](l, LumpMarkSurfaces)
+		for _, surf := range in16 {
+			in = append(in, uint32(surf))
+		}
+	} else {
+		in, err = readLump[uint32](l, LumpMarkSurfaces)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.mod.MarkSurfaces = make([]int, len(in))
+	for i, surf := range in {
+		if int(surf) >= len(l.mod.Surfaces) {
+			return fmt.Errorf("marksurface %d uses face %d of %d", i, surf, len(l.mod.Surfaces))
+		}
+		l.mod.MarkSurfaces[i] = int(surf)
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadLeafs() error {
+	var in []dLeafBSP2
+	var err error
+	switch l.format {
+	case FormatBSP29:
+		in, err = readConverted[dLeaf29](l, LumpLeafs)
+	case Format2PSB:
+		in, err = readConverted[dLeaf2PSB](l, LumpLeafs)
+	default:
+		in, err = readLump[dLeafBSP2](l, LumpLeafs)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.mod.Leafs = make([]MLeaf, len(in))
+	for i, lf := range in {
+		leaf := &l.mod.Leafs[i]
+		for j := 0; j < 3; j++ {
+			leaf.MinMaxs[j] = lf.Mins[j]
+			leaf.MinMaxs[3+j] = lf.Maxs[j]
+		}
+		leaf.Contents = int(lf.Contents)
+		leaf.AmbientSoundLevel = lf.AmbientLevel
+
+		leaf.FirstMarkSurface = int(lf.FirstMarkSurface)
+		leaf.NumMarkSurfaces = int(lf.NumMarkSurfaces)
+		if int64(lf.FirstMarkSurface)+int64(lf.NumMarkSurfaces) > int64(len(l.mod.MarkSurfaces)) {
+			return fmt.Errorf("leaf %d uses marksurfaces %d to %d of %d", i, leaf.FirstMarkSurface,
+				leaf.FirstMarkSurface+leaf.NumMarkSurfaces, len(l.mod.MarkSurfaces))
+		}
+
+		if lf.VisOfs != -1 {
+			if lf.VisOfs < 0 || int(lf.VisOfs) >= len(l.mod.VisData) {
+				return fmt.Errorf("leaf %d uses visibility at %d of %d", i, lf.VisOfs, len(l.mod.VisData))
+			}
+			leaf.CompressedVis = l.mod.VisData[lf.VisOfs:]
+		}
+
+		if leaf.Contents != ContentsEmpty {
+			for _, surf := range l.mod.MarkSurfaces[leaf.FirstMarkSurface : leaf.FirstMarkSurface+leaf.NumMarkSurfaces] {
+				l.mod.Surfaces[surf].Flags |= SurfUnderwater
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *brushLoader) loadNodes() error {
+	var in []dNodeBSP2
+	var err error
+	switch l.format {
+	case FormatBSP29:
+		in, err = readConverted[dNode29](l, LumpNodes)
+	case Format2PSB:
+		in, err = readConverted[dNode2PSB](l, LumpNodes)
+	default:
+		in, err = readLump[dNodeBSP2](l, LumpNodes)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.mod.Nodes = make([]MNode, len(in))
+	l.nodePlanes = make([]int, len(in))
+	for i, n := range in {
+		node := &l.mod.Nodes[i]
+		for j := 0; j < 3; j++ {
+			node.MinMaxs[j] = n.Mins[j]
+			node.MinMaxs[3+j] = n.Maxs[j]
+		}
+
+		if n.PlaneNum < 0 || int(n.PlaneNum) >= len(l.mod.Planes) {
+			return fmt.Errorf("node %d uses plane %d of %d", i, n.PlaneNum, len(l.mod.Planes))
+		}
+		node.Plane = &l.mod.Planes[n.PlaneNum]
+		l.nodePlanes[i] = int(n.PlaneNum)
+
+		node.FirstSurface = uint(n.FirstFace)
+		node.NumSurfaces = uint(n.NumFaces)
+		if int64(n.FirstFace)+int64(n.NumFaces) > int64(len(l.mod.Surfaces)) {
+			return fmt.Errorf("node %d uses faces %d to %d of %d", i, n.FirstFace, int64(n.FirstFace)+int64(n.NumFaces), len(l.mod.Surfaces))
+		}
+
+		for j, child := range n.Children {
+			node.Children[j] = l.unsignedIndex(child, len(in))
+			err = l.checkChild(node.Children[j])
+			if err != nil {
+				return fmt.Errorf("node %d: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *brushLoader) checkChild(child int) error {
+	if child >= 0 {
+		if child >= len(l.mod.Nodes) {
+			return fmt.Errorf("child node %d of %d", child, len(l.mod.Nodes))
+		}
+		return nil
+	}
+
+	leaf := -1 - child
+	if leaf >= len(l.mod.Leafs) {
+		return fmt.Errorf("child leaf %d of %d", leaf, len(l.mod.Leafs))
+	}
+	return nil
+}
+
+func (l *brushLoader) loadClipNodes() error {
+	var in []dClipNodeBSP2
+	var err error
+	if l.format == FormatBSP29 {
+		in, err = readConverted[dClipNode29](l, LumpClipNodes)
+	} else {
+		in, err = readLump[dClipNodeBSP2](l, LumpClipNodes)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.mod.ClipNodes = make([]MClipNode, len(in))
+	for i, c := range in {
+		clipNode := &l.mod.ClipNodes[i]
+		if c.PlaneNum < 0 || int(c.PlaneNum) >= len(l.mod.Planes) {
+			return fmt.Errorf("clipnode %d uses plane %d of %d", i, c.PlaneNum, len(l.mod.Planes))
+		}
+		clipNode.PlaneNum = int(c.PlaneNum)
+
+		for j, child := range c.Children {
+			clipNode.Children[j] = l.unsignedIndex(child, clipNodeContentsStart)
+			if clipNode.Children[j] >= len(in) {
+				return fmt.Errorf("clipnode %d has child clipnode %d of %d", i, clipNode.Children[j], len(in))
+			}
+			if clipNode.Children[j] < ContentsCurrentDown {
+				return fmt.Errorf("clipnode %d has child with contents %d", i, clipNode.Children[j])
+			}
+		}
+	}
+
+	for hull := 1; hull <= 2; hull++ {
+		l.mod.Hulls[hull] = Hull{
+			ClipNodes:     l.mod.ClipNodes,
+			Planes:        l.mod.Planes,
+			FirstClipNode: 0,
+			LastClipNode:  len(l.mod.ClipNodes) - 1,
+			ClipMins:      hullSizes[hull][0],
+			ClipMaxs:      hullSizes[hull][1],
+		}
+	}
+
+	return nil
+}
+
+// makeHull0 duplicates the drawing hull structure as a clipping hull
+func (l *brushLoader) makeHull0() {
+	clipNodes := make([]MClipNode, len(l.mod.Nodes))
+	for i := range l.mod.Nodes {
+		node := &l.mod.Nodes[i]
+		clipNodes[i].PlaneNum = l.nodePlanes[i]
+		for j, child := range node.Children {
+			if child < 0 {
+				clipNodes[i].Children[j] = l.mod.Leafs[-1-child].Contents
+			} else {
+				clipNodes[i].Children[j] = child
+			}
+		}
+	}
+
+	l.mod.Hulls[0] = Hull{
+		ClipNodes:     clipNodes,
+		Planes:        l.mod.Planes,
+		FirstClipNode: 0,
+		LastClipNode:  len(clipNodes) - 1,
+	}
+}
+
+func (l *brushLoader) loadSubmodels() error {
+	in, err := readLump[dModel](l, LumpModels)
+	if err != nil {
+		return err
+	}
+	if len(in) == 0 {
+		return fmt.Errorf("no models")
+	}
+
+	l.mod.Submodels = make([]DModel, len(in))
+	for i, m := range in {
+		sub := &l.mod.Submodels[i]
+		sub.Mins = m.Mins
+		sub.Maxs = m.Maxs
+		sub.Origin = m.Origin
+		// Spread the bounds by a pixel
+		for j := 0; j < 3; j++ {
+			sub.Mins[j] -= 1
+			sub.Maxs[j] += 1
+		}
+
+		for j, headNode := range m.HeadNode {
+			sub.HeadNode[j] = int(headNode)
+		}
+		if sub.HeadNode[0] < 0 || sub.HeadNode[0] >= len(l.mod.Nodes) {
+			return fmt.Errorf("model %d has head node %d of %d", i, sub.HeadNode[0], len(l.mod.Nodes))
+		}
+		// Maps compiled without clipping hulls have no clipnodes at all, and hull 3 is never built
+		// from the map's clipnodes, so only the player and shambler hulls have head clipnodes
+		for j := 1; j <= 2 && len(l.mod.ClipNodes) > 0; j++ {
+			if sub.HeadNode[j] < 0 || sub.HeadNode[j] >= len(l.mod.ClipNodes) {
+				return fmt.Errorf("model %d has head clipnode %d of %d for hull %d", i, sub.HeadNode[j], len(l.mod.ClipNodes), j)
+			}
+		}
+
+		sub.VisLeafs = int(m.VisLeafs)
+		if sub.VisLeafs < 0 || sub.VisLeafs >= len(l.mod.Leafs) {
+			return fmt.Errorf("model %d has %d visible leafs of %d", i, sub.VisLeafs, len(l.mod.Leafs))
+		}
+
+		sub.FirstFace = int(m.FirstFace)
+		sub.NumFaces = int(m.NumFaces)
+		if sub.FirstFace < 0 || sub.NumFaces < 0 || sub.FirstFace+sub.NumFaces > len(l.mod.Surfaces) {
+			return fmt.Errorf("model %d uses faces %d to %d of %d", i, sub.FirstFace, sub.FirstFace+sub.NumFaces, len(l.mod.Surfaces))
+		}
+	}
+
+	return nil
+}
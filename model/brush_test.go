@@ -0,0 +1,257 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vkngwrapper/quake/filesystem"
+)
+
+// buildBSP writes a bsp file with the given lumps.  Lumps are either raw bytes or slices of
+// records that are written with encoding/binary.
+func buildBSP(t *testing.T, version int, lumps map[int]any) []byte {
+	t.Helper()
+
+	header := Header{Version: int32(version)}
+	var body bytes.Buffer
+	headerSize := binary.Size(header)
+	for i := 0; i < NumLumps; i++ {
+		start := body.Len()
+		switch lump := lumps[i].(type) {
+		case nil:
+		case []byte:
+			body.Write(lump)
+		default:
+			err := binary.Write(&body, binary.LittleEndian, lump)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		header.Lumps[i] = Lump{FileOfs: int32(headerSize + start), FileLen: int32(body.Len() - start)}
+
+		for body.Len()%4 != 0 {
+			body.WriteByte(0)
+		}
+	}
+
+	var out bytes.Buffer
+	_ = binary.Write(&out, binary.LittleEndian, header)
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+// testTextures returns a texture lump with a single 16x16 texture
+func testTextures(name string) []byte {
+	var lump bytes.Buffer
+	_ = binary.Write(&lump, binary.LittleEndian, int32(1))
+	_ = binary.Write(&lump, binary.LittleEndian, int32(8))
+
+	mipTex := dMipTex{Width: 16, Height: 16}
+	copy(mipTex.Name[:], name)
+	offset := uint32(binary.Size(mipTex))
+	for i := range mipTex.Offsets {
+		mipTex.Offsets[i] = offset
+		offset += (16 >> i) * (16 >> i)
+	}
+	_ = binary.Write(&lump, binary.LittleEndian, mipTex)
+	lump.Write(make([]byte, offset-uint32(binary.Size(mipTex))))
+
+	return lump.Bytes()
+}
+
+// testLumps returns the lumps of a BSP29 map with a single 64x64 wall on the x = 0 plane, in
+// front of which is the only empty leaf
+func testLumps() map[int]any {
+	return map[int]any{
+		LumpEntities: []byte("{\n\"classname\" \"worldspawn\"\n}\n" +
+			"{\n\"classname\" \"info_player_start\"\n\"origin\" \"32 32 24\"\n}\n\x00"),
+		LumpPlanes: []dPlane{
+			{Normal: [3]float32{1, 0, 0}, Dist: 0, Type: 0},
+		},
+		LumpTextures: testTextures("wall"),
+		LumpVertices: []dVertex{
+			{[3]float32{0, 0, 0}}, {[3]float32{0, 64, 0}}, {[3]float32{0, 64, 64}}, {[3]float32{0, 0, 64}},
+		},
+		LumpVisibility: []byte{0x01},
+		LumpNodes: []dNode29{
+			{PlaneNum: 0, Children: [2]int16{-2, -1}, Maxs: [3]int16{64, 64, 64}, NumFaces: 1},
+		},
+		LumpTexInfo: []dTexInfo{
+			{Vecs: [2][4]float32{{0, 1, 0, 0}, {0, 0, -1, 0}}, MipTex: 0},
+		},
+		LumpFaces: []dFace29{
+			{PlaneNum: 0, NumEdges: 4, Styles: [MaxLightMaps]byte{0, 255, 255, 255}, LightOfs: 0},
+		},
+		// 5x5 samples for a 64x64 face
+		LumpLighting: bytes.Repeat([]byte{128}, 25),
+		LumpClipNodes: []dClipNode29{
+			{PlaneNum: 0, Children: [2]int16{int16(ContentsEmpty), int16(ContentsSolid)}},
+		},
+		LumpLeafs: []dLeaf29{
+			{Contents: int32(ContentsSolid), VisOfs: -1},
+			{Contents: int32(ContentsEmpty), VisOfs: 0, Maxs: [3]int16{64, 64, 64}, NumMarkSurfaces: 1},
+		},
+		LumpMarkSurfaces: []uint16{0},
+		LumpEdges:        []dEdge29{{}, {[2]uint16{0, 1}}, {[2]uint16{1, 2}}, {[2]uint16{2, 3}}, {[2]uint16{3, 0}}},
+		LumpSurfEdges:    []int32{1, 2, 3, 4},
+		LumpModels: []dModel{
+			{Maxs: [3]float32{64, 64, 64}, VisLeafs: 1, NumFaces: 1},
+		},
+	}
+}
+
+func TestLoadBrushModel(t *testing.T) {
+	baseDir := t.TempDir()
+	mapDir := filepath.Join(baseDir, filesystem.GameName, "maps")
+	err := os.MkdirAll(mapDir, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(mapDir, "test.bsp"), buildBSP(t, BSPVersion29, testLumps()), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := filesystem.New(filesystem.Options{BaseDir: baseDir})
+	err = files.AddGameDirectory(filesystem.GameName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	models, err := NewLoader(&out, files).LoadBrushModel("maps/test.bsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("expected 1 model, got %d", len(models))
+	}
+
+	world := models[0]
+	if world.Format() != FormatBSP29 || world.Name != "maps/test.bsp" {
+		t.Errorf("got %s model %s", world.Format(), world.Name)
+	}
+	if !strings.Contains(world.Entities, "info_player_start") {
+		t.Errorf("entities weren't loaded: %q", world.Entities)
+	}
+	if world.Textures[0] == nil || world.Textures[0].Name != "wall" {
+		t.Errorf("expected the wall texture, got %+v", world.Textures[0])
+	}
+	if len(world.Surfaces) != 1 || world.Surfaces[0].TexInfo.Texture != world.Textures[0] {
+		t.Fatalf("expected 1 surface with the wall texture, got %d", len(world.Surfaces))
+	}
+	if len(world.Surfaces[0].Samples) != 25*3 {
+		t.Errorf("expected 25 RGB samples, got %d bytes", len(world.Surfaces[0].Samples))
+	}
+	if world.NumLeafs != 1 || len(world.Leafs) != 2 {
+		t.Errorf("expected 1 visible leaf of 2, got %d of %d", world.NumLeafs, len(world.Leafs))
+	}
+	if world.Hulls[1].ClipNodes[0].Children != [2]int{ContentsEmpty, ContentsSolid} {
+		t.Errorf("got clipnode children %v", world.Hulls[1].ClipNodes[0].Children)
+	}
+}
+
+func TestBrushClipNodeChildren(t *testing.T) {
+	// BSP29 children from 0xfff0 up are contents
+	lumps := testLumps()
+	lumps[LumpClipNodes] = []dClipNode29{{Children: [2]int16{int16(ContentsWater), int16(ContentsCurrentDown)}}}
+	models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if children := models[0].ClipNodes[0].Children; children != [2]int{ContentsWater, ContentsCurrentDown} {
+		t.Errorf("got clipnode children %v", children)
+	}
+
+	// Below 0xfff0 they're clipnodes, even past the number of clipnodes in the map
+	lumps[LumpClipNodes] = []dClipNode29{{Children: [2]int16{-100, int16(ContentsSolid)}}}
+	_, err = ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err == nil {
+		t.Error("clipnode child 0xff9c was accepted")
+	}
+
+	lumps[LumpClipNodes] = []dClipNode29{{Children: [2]int16{-15, int16(ContentsSolid)}}}
+	_, err = ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err == nil {
+		t.Error("clipnode child with contents -15 was accepted")
+	}
+
+	lumps[LumpClipNodes] = []dClipNodeBSP2{{Children: [2]int32{-15, int32(ContentsSolid)}}}
+	_, err = ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion2, bsp2Lumps(lumps)), nil)
+	if err == nil {
+		t.Error("BSP2 clipnode child with contents -15 was accepted")
+	}
+}
+
+// bsp2Lumps converts the lumps that differ between BSP29 and BSP2
+func bsp2Lumps(lumps map[int]any) map[int]any {
+	converted := make(map[int]any, len(lumps))
+	for i, lump := range lumps {
+		converted[i] = lump
+	}
+
+	converted[LumpNodes] = []dNodeBSP2{
+		{PlaneNum: 0, Children: [2]int32{-2, -1}, Maxs: [3]float32{64, 64, 64}, NumFaces: 1},
+	}
+	converted[LumpFaces] = []dFaceBSP2{
+		{PlaneNum: 0, NumEdges: 4, Styles: [MaxLightMaps]byte{0, 255, 255, 255}, LightOfs: 0},
+	}
+	converted[LumpLeafs] = []dLeafBSP2{
+		{Contents: int32(ContentsSolid), VisOfs: -1},
+		{Contents: int32(ContentsEmpty), VisOfs: 0, Maxs: [3]float32{64, 64, 64}, NumMarkSurfaces: 1},
+	}
+	converted[LumpMarkSurfaces] = []uint32{0}
+	converted[LumpEdges] = []dEdgeBSP2{{}, {[2]uint32{0, 1}}, {[2]uint32{1, 2}}, {[2]uint32{2, 3}}, {[2]uint32{3, 0}}}
+
+	return converted
+}
+
+func TestBrushNegativeHeadNode(t *testing.T) {
+	lumps := testLumps()
+	lumps[LumpModels] = []dModel{
+		{Maxs: [3]float32{64, 64, 64}, HeadNode: [MaxMapHulls]int32{0, -1, 0, 0}, VisLeafs: 1, NumFaces: 1},
+	}
+
+	_, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err == nil {
+		t.Error("negative head clipnode was accepted")
+	}
+}
+
+func TestBrushLightOffsetOutOfRange(t *testing.T) {
+	lumps := testLumps()
+	lumps[LumpFaces] = []dFace29{
+		{PlaneNum: 0, NumEdges: 4, Styles: [MaxLightMaps]byte{0, 255, 255, 255}, LightOfs: 1000},
+	}
+
+	models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models[0].Surfaces[0].Samples != nil {
+		t.Error("face with lighting outside of the lump has samples")
+	}
+}
+
+func TestBrushWithoutClipNodes(t *testing.T) {
+	// Maps built with qbsp -noclip have no clipnodes, and their head clipnodes can be anything
+	lumps := testLumps()
+	delete(lumps, LumpClipNodes)
+	lumps[LumpModels] = []dModel{
+		{Maxs: [3]float32{64, 64, 64}, HeadNode: [MaxMapHulls]int32{0, 0, 0, -1}, VisLeafs: 1, NumFaces: 1},
+	}
+
+	models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hull := 1; hull <= 2; hull++ {
+		if clipNodes := models[0].Hulls[hull].ClipNodes; len(clipNodes) != 0 {
+			t.Errorf("hull %d has %d clipnodes", hull, len(clipNodes))
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package model
+
+import "fmt"
+
+const (
+	MaxLightMaps int = 4
+	MaxMapHulls  int = 4
+	MipLevels    int = 4
+	NumAmbients  int = 4
+)
+
+const (
+	BSPVersion29   int = 29
+	BSPVersion2PSB int = 'B'<<24 | 'S'<<16 | 'P'<<8 | '2' // RMQ's first take on larger limits
+	BSPVersion2    int = '2'<<24 | 'P'<<16 | 'S'<<8 | 'B'
+)
+
+// Format is one of the bsp file formats.  The extended formats use 32 bit indices to raise the
+// limits on map size and complexity.
+type Format int
+
+const (
+	FormatBSP29 Format = iota
+	Format2PSB
+	FormatBSP2
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatBSP29:
+		return "BSP29"
+	case Format2PSB:
+		return "2PSB"
+	case FormatBSP2:
+		return "BSP2"
+	}
+
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// FormatForVersion returns the format of bsp files with the version number, if it's one we can load
+func FormatForVersion(version int) (Format, bool) {
+	switch version {
+	case BSPVersion29:
+		return FormatBSP29, true
+	case BSPVersion2PSB:
+		return Format2PSB, true
+	case BSPVersion2:
+		return FormatBSP2, true
+	}
+
+	return 0, false
+}
+
+const (
+	LumpEntities int = iota
+	LumpPlanes
+	LumpTextures
+	LumpVertices
+	LumpVisibility
+	LumpNodes
+	LumpTexInfo
+	LumpFaces
+	LumpLighting
+	LumpClipNodes
+	LumpLeafs
+	LumpMarkSurfaces
+	LumpEdges
+	LumpSurfEdges
+	LumpModels
+	NumLumps
+)
+
+var LumpNames = [NumLumps]string{
+	"entities",
+	"planes",
+	"textures",
+	"vertices",
+	"visibility",
+	"nodes",
+	"texinfo",
+	"faces",
+	"lighting",
+	"clipnodes",
+	"leafs",
+	"marksurfaces",
+	"edges",
+	"surfedges",
+	"models",
+}
+
+const (
+	ContentsEmpty int = -1 - iota
+	ContentsSolid
+	ContentsWater
+	ContentsSlime
+	ContentsLava
+	ContentsSky
+	ContentsOrigin // removed at csg time
+	ContentsClip   // changed to ContentsSolid
+	ContentsCurrent0
+	ContentsCurrent90
+	ContentsCurrent180
+	ContentsCurrent270
+	ContentsCurrentUp
+	ContentsCurrentDown
+)
+
+// Texinfo flags
+const (
+	TexSpecial int = 1 // sky or slime, no lightmap or 256 subdivision
+	TexMissing int = 2 // the texinfo's texture isn't in the map
+)
+
+type DModel struct {
+	Mins      [3]float32
+	Maxs      [3]float32
+	Origin    [3]float32
+	HeadNode  [MaxMapHulls]int
+	VisLeafs  int // not including the solid leaf 0
+	FirstFace int
+	NumFaces  int
+}
+
+const (
+	PlaneX    int = 0
+	PlaneY    int = 1
+	PlaneZ    int = 2
+	PlaneAnyX int = 3
+	PlaneAnyY int = 4
+	PlaneAnyZ int = 5
+)
+
+type Lump struct {
+	FileOfs int32
+	FileLen int32
+}
+
+// Header is found at the start of every bsp file
+type Header struct {
+	Version int32
+	Lumps   [NumLumps]Lump
+}
+
+// The remaining types are the layout of records in the lumps of a bsp file
+
+type dModel struct {
+	Mins      [3]float32
+	Maxs      [3]float32
+	Origin    [3]float32
+	HeadNode  [MaxMapHulls]int32
+	VisLeafs  int32
+	FirstFace int32
+	NumFaces  int32
+}
+
+type dVertex struct {
+	Point [3]float32
+}
+
+type dPlane struct {
+	Normal [3]float32
+	Dist   float32
+	Type   int32
+}
+
+type dMipTex struct {
+	Name    [16]byte
+	Width   uint32
+	Height  uint32
+	Offsets [MipLevels]uint32
+}
+
+type dTexInfo struct {
+	Vecs   [2][4]float32
+	MipTex int32
+	Flags  int32
+}
+
+type dNode29 struct {
+	PlaneNum  int32
+	Children  [2]int16 // negative numbers are -(leafs+1), not nodes
+	Mins      [3]int16
+	Maxs      [3]int16
+	FirstFace uint16
+	NumFaces  uint16 // counting both sides
+}
+
+type dNode2PSB struct {
+	PlaneNum  int32
+	Children  [2]int32
+	Mins      [3]int16
+	Maxs      [3]int16
+	FirstFace uint32
+	NumFaces  uint32
+}
+
+type dNodeBSP2 struct {
+	PlaneNum  int32
+	Children  [2]int32
+	Mins      [3]float32
+	Maxs      [3]float32
+	FirstFace uint32
+	NumFaces  uint32
+}
+
+type dClipNode29 struct {
+	PlaneNum int32
+	Children [2]int16 // negative numbers are contents
+}
+
+type dClipNodeBSP2 struct {
+	PlaneNum int32
+	Children [2]int32
+}
+
+type dEdge29 struct {
+	V [2]uint16
+}
+
+type dEdgeBSP2 struct {
+	V [2]uint32
+}
+
+type dFace29 struct {
+	PlaneNum  int16
+	Side      int16
+	FirstEdge int32
+	NumEdges  int16
+	TexInfo   int16
+	Styles    [MaxLightMaps]byte
+	LightOfs  int32 // start of [numstyles*surfsize] samples
+}
+
+type dFaceBSP2 struct {
+	PlaneNum  int32
+	Side      int32
+	FirstEdge int32
+	NumEdges  int32
+	TexInfo   int32
+	Styles    [MaxLightMaps]byte
+	LightOfs  int32
+}
+
+type dLeaf29 struct {
+	Contents         int32
+	VisOfs           int32 // -1 = no visibility info
+	Mins             [3]int16
+	Maxs             [3]int16
+	FirstMarkSurface uint16
+	NumMarkSurfaces  uint16
+	AmbientLevel     [NumAmbients]byte
+}
+
+type dLeaf2PSB struct {
+	Contents         int32
+	VisOfs           int32
+	Mins             [3]int16
+	Maxs             [3]int16
+	FirstMarkSurface uint32
+	NumMarkSurfaces  uint32
+	AmbientLevel     [NumAmbients]byte
+}
+
+type dLeafBSP2 struct {
+	Contents         int32
+	VisOfs           int32
+	Mins             [3]float32
+	Maxs             [3]float32
+	FirstMarkSurface uint32
+	NumMarkSurfaces  uint32
+	AmbientLevel     [NumAmbients]byte
+}
+
+// Records of every format are converted to the widest one before they're loaded
+
+type converter[T any] interface {
+	convert() T
+}
+
+// convert leaves the children unsigned, since there can be more than 32767 nodes in a BSP29 map
+func (n dNode29) convert() dNodeBSP2 {
+	return dNodeBSP2{
+		PlaneNum:  n.PlaneNum,
+		Children:  [2]int32{int32(uint16(n.Children[0])), int32(uint16(n.Children[1]))},
+		Mins:      int16sToFloats(n.Mins),
+		Maxs:      int16sToFloats(n.Maxs),
+		FirstFace: uint32(n.FirstFace),
+		NumFaces:  uint32(n.NumFaces),
+	}
+}
+
+func (n dNode2PSB) convert() dNodeBSP2 {
+	return dNodeBSP2{
+		PlaneNum:  n.PlaneNum,
+		Children:  n.Children,
+		Mins:      int16sToFloats(n.Mins),
+		Maxs:      int16sToFloats(n.Maxs),
+		FirstFace: n.FirstFace,
+		NumFaces:  n.NumFaces,
+	}
+}
+
+func (n dNodeBSP2) convert() dNodeBSP2 {
+	return n
+}
+
+// convert leaves the children unsigned, like dNode29
+func (c dClipNode29) convert() dClipNodeBSP2 {
+	return dClipNodeBSP2{
+		PlaneNum: c.PlaneNum,
+		Children: [2]int32{int32(uint16(c.Children[0])), int32(uint16(c.Children[1]))},
+	}
+}
+
+func (c dClipNodeBSP2) convert() dClipNodeBSP2 {
+	return c
+}
+
+func (e dEdge29) convert() dEdgeBSP2 {
+	return dEdgeBSP2{V: [2]uint32{uint32(e.V[0]), uint32(e.V[1])}}
+}
+
+func (e dEdgeBSP2) convert() dEdgeBSP2 {
+	return e
+}
+
+func (f dFace29) convert() dFaceBSP2 {
+	return dFaceBSP2{
+		PlaneNum:  int32(f.PlaneNum),
+		Side:      int32(f.Side),
+		FirstEdge: f.FirstEdge,
+		NumEdges:  int32(f.NumEdges),
+		TexInfo:   int32(f.TexInfo),
+		Styles:    f.Styles,
+		LightOfs:  f.LightOfs,
+	}
+}
+
+func (f dFaceBSP2) convert() dFaceBSP2 {
+	return f
+}
+
+func (l dLeaf29) convert() dLeafBSP2 {
+	return dLeafBSP2{
+		Contents:         l.Contents,
+		VisOfs:           l.VisOfs,
+		Mins:             int16sToFloats(l.Mins),
+		Maxs:             int16sToFloats(l.Maxs),
+		FirstMarkSurface: uint32(l.FirstMarkSurface),
+		NumMarkSurfaces:  uint32(l.NumMarkSurfaces),
+		AmbientLevel:     l.AmbientLevel,
+	}
+}
+
+func (l dLeaf2PSB) convert() dLeafBSP2 {
+	return dLeafBSP2{
+		Contents:         l.Contents,
+		VisOfs:           l.VisOfs,
+		Mins:             int16sToFloats(l.Mins),
+		Maxs:             int16sToFloats(l.Maxs),
+		FirstMarkSurface: l.FirstMarkSurface,
+		NumMarkSurfaces:  l.NumMarkSurfaces,
+		AmbientLevel:     l.AmbientLevel,
+	}
+}
+
+func (l dLeafBSP2) convert() dLeafBSP2 {
+	return l
+}
+
+func int16sToFloats(v [3]int16) [3]float32 {
+	return [3]float32{float32(v[0]), float32(v[1]), float32(v[2])}
+}
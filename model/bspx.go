@@ -0,0 +1,130 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+const BSPXNameLength = 24
+
+var BSPXID = [4]byte{'B', 'S', 'P', 'X'}
+
+// Names of the BSPX lumps the loader uses
+const (
+	BSPXRGBLighting   = "RGBLIGHTING"  // 3 bytes per sample, in place of the lighting lump
+	BSPXLightMapShift = "LMSHIFT"      // 1 byte per face, log2 of the world units per lightmap sample
+	BSPXLightMapOfs   = "LMOFFSET"     // 1 int32 per face, lighting offsets for LMSHIFT lightmaps
+	BSPXLightMapStyle = "LMSTYLE"      // MaxLightMaps bytes per face, styles for LMSHIFT lightmaps
+	BSPXDecoupledLM   = "DECOUPLED_LM" // 1 dDecoupledLM per face
+	BSPXBrushList     = "BRUSHLIST"    // brushes of each model, for collision
+)
+
+type bspxHeader struct {
+	ID       [4]byte
+	NumLumps int32
+}
+
+type bspxLump struct {
+	Name    [BSPXNameLength]byte
+	FileOfs int32
+	FileLen int32
+}
+
+// dDecoupledLM describes a lightmap that isn't aligned with the face's texture
+type dDecoupledLM struct {
+	LightMapSize [2]uint16
+	LightOfs     int32
+	WorldToLM    [2][4]float32
+}
+
+// BSPX is the directory of extra lumps that some compilers add after the standard lumps of a
+// bsp file
+type BSPX struct {
+	lumps map[string][]byte
+}
+
+// ReadBSPX finds the BSPX directory that follows the last standard lump of a bsp file.  It
+// returns nil if the file doesn't have one.
+func ReadBSPX(data []byte, header *Header) (*BSPX, error) {
+	var offset int64
+	for _, lump := range header.Lumps {
+		offset = max(offset, (int64(lump.FileOfs)+int64(lump.FileLen)+3)&^3)
+	}
+	if offset < int64(binary.Size(header)) {
+		offset = int64(binary.Size(header))
+	}
+	if offset >= int64(len(data)) {
+		return nil, nil
+	}
+
+	reader := bytes.NewReader(data[offset:])
+	var bspxHead bspxHeader
+	err := binary.Read(reader, binary.LittleEndian, &bspxHead)
+	if err != nil || bspxHead.ID != BSPXID {
+		return nil, nil
+	}
+
+	if bspxHead.NumLumps < 0 || int64(bspxHead.NumLumps)*int64(binary.Size(bspxLump{})) > int64(reader.Len()) {
+		return nil, fmt.Errorf("BSPX directory is too short for %d lumps", bspxHead.NumLumps)
+	}
+
+	entries := make([]bspxLump, bspxHead.NumLumps)
+	_ = binary.Read(reader, binary.LittleEndian, entries)
+
+	bspx := &BSPX{lumps: make(map[string][]byte, len(entries))}
+	for _, entry := range entries {
+		name := entry.Name[:]
+		nullIndex := bytes.IndexByte(name, 0)
+		if nullIndex >= 0 {
+			name = name[:nullIndex]
+		}
+
+		if entry.FileOfs < 0 || entry.FileLen < 0 || int64(entry.FileOfs)+int64(entry.FileLen) > int64(len(data)) {
+			return nil, fmt.Errorf("BSPX lump %s (offset %d, length %d) is outside of the file (%d bytes)",
+				name, entry.FileOfs, entry.FileLen, len(data))
+		}
+		bspx.lumps[string(name)] = data[entry.FileOfs : entry.FileOfs+entry.FileLen]
+	}
+
+	return bspx, nil
+}
+
+// Lump returns the contents of the named lump, or nil if there isn't one
+func (x *BSPX) Lump(name string) []byte {
+	if x == nil {
+		return nil
+	}
+
+	return x.lumps[name]
+}
+
+// Names returns the names of all of the lumps, sorted
+func (x *BSPX) Names() []string {
+	if x == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(x.lumps))
+	for name := range x.lumps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// faceLump decodes a BSPX lump with one record per face.  The lump is ignored if it doesn't have
+// exactly one record for each face.
+func faceLump[T any](x *BSPX, name string, faceCount int) []T {
+	data := x.Lump(name)
+	var record T
+	if data == nil || len(data) != faceCount*binary.Size(record) {
+		return nil
+	}
+
+	records := make([]T, faceCount)
+	_ = binary.Read(bytes.NewReader(data), binary.LittleEndian, records)
+	return records
+}
@@ -0,0 +1,86 @@
+package model
+
+import "github.com/vkngwrapper/math"
+
+// PointInLeaf returns the leaf of the model's BSP tree that a point is in
+func (m *QModel) PointInLeaf(p math.Vec3[float32]) *MLeaf {
+	node := m.Hulls[0].FirstClipNode
+	for node >= 0 {
+		plane := m.Nodes[node].Plane
+		d := p.DotProduct(&plane.Normal) - plane.Dist
+		if d > 0 {
+			node = m.Nodes[node].Children[0]
+		} else {
+			node = m.Nodes[node].Children[1]
+		}
+	}
+
+	return &m.Leafs[-1-node]
+}
+
+// planeDist returns how far in front of a plane a point is
+func planeDist(plane *MPlane, p *math.Vec3[float32]) float32 {
+	switch int(plane.Type) {
+	case PlaneX:
+		return p.X - plane.Dist
+	case PlaneY:
+		return p.Y - plane.Dist
+	case PlaneZ:
+		return p.Z - plane.Dist
+	}
+
+	return float32(float64(plane.Normal.X)*float64(p.X)+float64(plane.Normal.Y)*float64(p.Y)+
+		float64(plane.Normal.Z)*float64(p.Z)) - plane.Dist
+}
+
+// PointContents returns the contents of the hull at a point, starting at clipnode num.  Hulls
+// without clipnodes, like hull 3 or every hull of a map built without clipping hulls, are empty
+// everywhere.
+func (h *Hull) PointContents(num int, p math.Vec3[float32]) int {
+	if num >= 0 && len(h.ClipNodes) == 0 {
+		return ContentsEmpty
+	}
+
+	for num >= 0 {
+		node := &h.ClipNodes[num]
+		plane := &h.Planes[node.PlaneNum]
+
+		if planeDist(plane, &p) < 0 {
+			num = node.Children[1]
+		} else {
+			num = node.Children[0]
+		}
+	}
+
+	return num
+}
+
+// HullPointContents returns the contents of one of the model's hulls at a point.  Hull 0 is the
+// model's own shape, hulls 1 and 2 are expanded for player and shambler sized boxes.
+func (m *QModel) HullPointContents(hull int, p math.Vec3[float32]) int {
+	h := &m.Hulls[hull]
+	return h.PointContents(h.FirstClipNode, p)
+}
+
+// PointContents returns the contents of the model at a point, with currents treated as water
+func (m *QModel) PointContents(p math.Vec3[float32]) int {
+	contents := m.HullPointContents(0, p)
+	if contents <= ContentsCurrent0 && contents >= ContentsCurrentDown {
+		contents = ContentsWater
+	}
+
+	return contents
+}
+
+// PointContentsBatch fills contents with the contents of the model at each of points, for callers
+// like the particle system that check many points at once
+func (m *QModel) PointContentsBatch(points []math.Vec3[float32], contents []int) {
+	hull := &m.Hulls[0]
+	for i := range points {
+		c := hull.PointContents(hull.FirstClipNode, points[i])
+		if c <= ContentsCurrent0 && c >= ContentsCurrentDown {
+			c = ContentsWater
+		}
+		contents[i] = c
+	}
+}
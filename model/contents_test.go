@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/vkngwrapper/math"
+)
+
+func TestHullPointContents(t *testing.T) {
+	models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, testLumps()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := models[0]
+
+	tests := []struct {
+		hull int
+		p    math.Vec3[float32]
+		want int
+	}{
+		{hull: 0, p: math.Vec3[float32]{X: 32, Y: 32, Z: 32}, want: ContentsEmpty},
+		{hull: 0, p: math.Vec3[float32]{X: -32, Y: 32, Z: 32}, want: ContentsSolid},
+		{hull: 1, p: math.Vec3[float32]{X: 32, Y: 32, Z: 32}, want: ContentsEmpty},
+		{hull: 2, p: math.Vec3[float32]{X: -32, Y: 32, Z: 32}, want: ContentsSolid},
+		// The map has no clipnodes for hull 3
+		{hull: 3, p: math.Vec3[float32]{X: -32, Y: 32, Z: 32}, want: ContentsEmpty},
+	}
+
+	for _, test := range tests {
+		if got := world.HullPointContents(test.hull, test.p); got != test.want {
+			t.Errorf("hull %d at %v: got contents %d, want %d", test.hull, test.p, got, test.want)
+		}
+	}
+}
+
+func TestHullPointContentsWithoutClipNodes(t *testing.T) {
+	lumps := testLumps()
+	delete(lumps, LumpClipNodes)
+	models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := models[0]
+
+	p := math.Vec3[float32]{X: -32, Y: 32, Z: 32}
+	if got := world.HullPointContents(0, p); got != ContentsSolid {
+		t.Errorf("hull 0: got contents %d, want %d", got, ContentsSolid)
+	}
+	for hull := 1; hull < MaxMapHulls; hull++ {
+		if got := world.HullPointContents(hull, p); got != ContentsEmpty {
+			t.Errorf("hull %d: got contents %d, want %d", hull, got, ContentsEmpty)
+		}
+	}
+
+	// Starting from contents rather than a clipnode gives those contents
+	if got := world.Hulls[1].PointContents(ContentsSolid, p); got != ContentsSolid {
+		t.Errorf("got contents %d, want %d", got, ContentsSolid)
+	}
+}
@@ -0,0 +1,259 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vkngwrapper/math"
+	"github.com/vkngwrapper/quake/parse"
+)
+
+// EntityField is one of an entity's keys and its value
+type EntityField struct {
+	Key   string
+	Value string
+}
+
+// Entity is one of the entities in a map's entity lump, with its fields in the order they appear
+type Entity struct {
+	Fields []EntityField
+	Line   int // line of the lump that the entity starts on
+}
+
+// EntityError is a problem with the entity lump, found on Line
+type EntityError struct {
+	Line int
+	Msg  string
+}
+
+func (e *EntityError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// entityParser reads tokens from the entity lump, keeping track of the line it's on.  The lump is
+// in the Quake charset rather than UTF-8, so each byte is held as the rune of the same value.
+type entityParser struct {
+	data []rune
+	line int
+}
+
+func newEntityParser(text string) *entityParser {
+	data := make([]rune, len(text))
+	for i := 0; i < len(text); i++ {
+		data[i] = rune(text[i])
+	}
+
+	return &entityParser{data: data, line: 1}
+}
+
+func (p *entityParser) next() (string, bool) {
+	token, remaining := parse.NextToken(p.data, parse.OverflowTruncate)
+	if remaining == nil {
+		p.data = nil
+		return "", false
+	}
+
+	for _, r := range p.data[:len(p.data)-len(remaining)] {
+		if r == '\n' {
+			p.line++
+		}
+	}
+	p.data = remaining
+
+	// Turn the token's runes back into the bytes they came from
+	tokenBytes := make([]byte, 0, len(token))
+	for _, r := range token {
+		tokenBytes = append(tokenBytes, byte(r))
+	}
+	return string(tokenBytes), true
+}
+
+// ParseEntities parses an entity lump, which is a series of entities in braces, each holding
+// quoted key and value pairs
+func ParseEntities(text string) ([]*Entity, error) {
+	p := newEntityParser(text)
+	var entities []*Entity
+
+	for {
+		token, ok := p.next()
+		if !ok {
+			return entities, nil
+		}
+		if token != "{" {
+			return nil, &EntityError{Line: p.line, Msg: fmt.Sprintf("found %q when expecting {", token)}
+		}
+
+		entity := &Entity{Line: p.line}
+		for {
+			key, ok := p.next()
+			if !ok {
+				return nil, &EntityError{Line: p.line, Msg: "EOF without closing brace"}
+			}
+			if key == "}" {
+				break
+			}
+			if key == "{" {
+				return nil, &EntityError{Line: p.line, Msg: "found { inside an entity"}
+			}
+
+			value, ok := p.next()
+			if !ok {
+				return nil, &EntityError{Line: p.line, Msg: "EOF without closing brace"}
+			}
+			if value == "}" || value == "{" {
+				return nil, &EntityError{Line: p.line, Msg: fmt.Sprintf("key %q has no value", key)}
+			}
+
+			// Some editors add trailing spaces to keys
+			entity.Fields = append(entity.Fields, EntityField{Key: strings.TrimRight(key, " "), Value: value})
+		}
+
+		entities = append(entities, entity)
+	}
+}
+
+// FormatEntities writes entities back out in the format of an entity lump or .ent file.  It fails
+// if a key or value can't be written in a form that ParseEntities would read back.
+func FormatEntities(entities []*Entity) (string, error) {
+	var builder strings.Builder
+	for _, entity := range entities {
+		builder.WriteString("{\n")
+		for _, field := range entity.Fields {
+			err := checkEntityField(field.Key, field.Value)
+			if err != nil {
+				return "", &EntityError{Line: entity.Line, Msg: err.Error()}
+			}
+
+			_, _ = fmt.Fprintf(&builder, "\"%s\" \"%s\"\n", field.Key, field.Value)
+		}
+		builder.WriteString("}\n")
+	}
+
+	return builder.String(), nil
+}
+
+// checkEntityField returns an error if a key or value would change meaning when it's written
+// out in quotes.  Entity lumps have no escapes, so quotes and line breaks can't be written, and
+// a lone brace would be read back as the end of the entity.
+func checkEntityField(key, value string) error {
+	for _, s := range []string{key, value} {
+		if strings.ContainsAny(s, "\"\r\n") {
+			return fmt.Errorf("%q can't contain quotes or line breaks", s)
+		}
+		if s == "{" || s == "}" {
+			return fmt.Errorf("%q can't be a brace", s)
+		}
+	}
+
+	return nil
+}
+
+// Value returns the value of a key, and whether the entity has it
+func (e *Entity) Value(key string) (string, bool) {
+	for _, field := range e.Fields {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// ClassName returns the entity's classname, or "" if it doesn't have one
+func (e *Entity) ClassName() string {
+	className, _ := e.Value("classname")
+	return className
+}
+
+// Set changes the value of a key, adding it to the end of the entity if it doesn't have it.  Keys
+// and values can't contain quotes or line breaks.
+func (e *Entity) Set(key, value string) error {
+	err := checkEntityField(key, value)
+	if err != nil {
+		return err
+	}
+
+	for i := range e.Fields {
+		if e.Fields[i].Key == key {
+			e.Fields[i].Value = value
+			return nil
+		}
+	}
+
+	e.Fields = append(e.Fields, EntityField{Key: key, Value: value})
+	return nil
+}
+
+// Remove removes a key from the entity
+func (e *Entity) Remove(key string) {
+	for i := range e.Fields {
+		if e.Fields[i].Key == key {
+			e.Fields = append(e.Fields[:i], e.Fields[i+1:]...)
+			return
+		}
+	}
+}
+
+func (e *Entity) requiredValue(key string) (string, error) {
+	value, ok := e.Value(key)
+	if !ok {
+		return "", &EntityError{Line: e.Line, Msg: fmt.Sprintf("%s has no %s", e.ClassName(), key)}
+	}
+
+	return value, nil
+}
+
+// Float returns the value of a key as a number
+func (e *Entity) Float(key string) (float32, error) {
+	value, err := e.requiredValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 32)
+	if err != nil {
+		return 0, &EntityError{Line: e.Line, Msg: fmt.Sprintf("%s of %s isn't a number: %q", key, e.ClassName(), value)}
+	}
+
+	return float32(f), nil
+}
+
+// Int returns the value of a key as an integer
+func (e *Entity) Int(key string) (int, error) {
+	value, err := e.requiredValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, &EntityError{Line: e.Line, Msg: fmt.Sprintf("%s of %s isn't an integer: %q", key, e.ClassName(), value)}
+	}
+
+	return i, nil
+}
+
+// Vector returns the value of a key, such as an origin or color, that holds three numbers
+func (e *Entity) Vector(key string) (math.Vec3[float32], error) {
+	value, err := e.requiredValue(key)
+	if err != nil {
+		return math.Vec3[float32]{}, err
+	}
+
+	components := strings.Fields(value)
+	if len(components) != 3 {
+		return math.Vec3[float32]{}, &EntityError{Line: e.Line, Msg: fmt.Sprintf("%s of %s isn't a vector: %q", key, e.ClassName(), value)}
+	}
+
+	var v [3]float32
+	for i, component := range components {
+		f, err := strconv.ParseFloat(component, 32)
+		if err != nil {
+			return math.Vec3[float32]{}, &EntityError{Line: e.Line, Msg: fmt.Sprintf("%s of %s isn't a vector: %q", key, e.ClassName(), value)}
+		}
+		v[i] = float32(f)
+	}
+
+	return math.Vec3[float32]{X: v[0], Y: v[1], Z: v[2]}, nil
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testEntities = `{
+"classname" "worldspawn"
+"message" "The Slipgate Complex"
+"wad" "gfx/base.wad"
+}
+{
+"classname" "info_player_start"
+"origin" "480 -352 88"
+"angle" "90"
+}
+{
+"classname" "light"
+"origin" "0 0 0"
+"_color" "1 0.5 0.5"
+"light" "300"
+}
+`
+
+// fieldsOf returns the fields of each entity, without the lines they started on
+func fieldsOf(entities []*Entity) [][]EntityField {
+	fields := make([][]EntityField, len(entities))
+	for i, entity := range entities {
+		fields[i] = entity.Fields
+	}
+
+	return fields
+}
+
+func TestEntitiesRoundTrip(t *testing.T) {
+	entities, err := ParseEntities(testEntities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 3 {
+		t.Fatalf("expected 3 entities, got %d", len(entities))
+	}
+
+	formatted, err := FormatEntities(entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != testEntities {
+		t.Errorf("formatted entities don't match the lump:\n%s", formatted)
+	}
+
+	err = entities[2].Set("light", "500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = entities[2].Set("target", "t1; quit // not a command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities[1].Remove("angle")
+
+	formatted, err = FormatEntities(entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := ParseEntities(formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fieldsOf(reparsed), fieldsOf(entities)) {
+		t.Errorf("got %+v, want %+v", fieldsOf(reparsed), fieldsOf(entities))
+	}
+}
+
+func TestEntitiesRoundTripQuakeCharset(t *testing.T) {
+	// Gold text and the other high characters aren't UTF-8
+	lump := "{\n\"classname\" \"worldspawn\"\n\"message\" \"\xc8\xe5\xec\xec\xef \x80\x81\x82\xff\"\n}\n"
+	entities, err := ParseEntities(lump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, _ := entities[0].Value("message")
+	if message != "\xc8\xe5\xec\xec\xef \x80\x81\x82\xff" {
+		t.Errorf("got message %q", message)
+	}
+
+	formatted, err := FormatEntities(entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != lump {
+		t.Errorf("got %q, want %q", formatted, lump)
+	}
+}
+
+func TestEntitySetRejectsUnwritableFields(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{key: "message", value: "say \"hello\""},
+		{key: "message", value: "two\nlines"},
+		{key: "message", value: "carriage\rreturn"},
+		{key: "mess\"age", value: "hello"},
+		{key: "message\n", value: "hello"},
+		{key: "message", value: "}"},
+		{key: "{", value: "hello"},
+	}
+
+	for _, test := range tests {
+		entity := &Entity{Fields: []EntityField{{Key: "classname", Value: "trigger_once"}}}
+		if err := entity.Set(test.key, test.value); err == nil {
+			t.Errorf("set %q to %q", test.key, test.value)
+		}
+		if len(entity.Fields) != 1 {
+			t.Errorf("setting %q to %q changed the entity: %+v", test.key, test.value, entity.Fields)
+		}
+	}
+}
+
+func TestFormatEntitiesRejectsUnwritableFields(t *testing.T) {
+	entities := []*Entity{{
+		Fields: []EntityField{{Key: "classname", Value: "worldspawn"}, {Key: "message", Value: "say \"hello\""}},
+		Line:   1,
+	}}
+
+	_, err := FormatEntities(entities)
+	if err == nil {
+		t.Error("entity with a quote in a value was formatted")
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const LitVersion = 1
+
+var LitID = [4]byte{'Q', 'L', 'I', 'T'}
+
+// LitHeader is found at the start of a .lit file, which holds colored lighting for the map of the
+// same name.  It's followed by 3 bytes for each sample in the map's lighting lump.
+type LitHeader struct {
+	ID      [4]byte
+	Version int32
+}
+
+// LitPath returns the path of the .lit file for a map
+func LitPath(mapName string) string {
+	return strings.TrimSuffix(mapName, path.Ext(mapName)) + ".lit"
+}
+
+// ValidateLit checks that a .lit file is one we can load and has lighting for every sample in a
+// map's lighting lump
+func ValidateLit(lit []byte, header *Header) error {
+	var litHeader LitHeader
+	err := binary.Read(bytes.NewReader(lit), binary.LittleEndian, &litHeader)
+	if err != nil || litHeader.ID != LitID {
+		return fmt.Errorf("corrupt .lit file (old version?)")
+	}
+	if litHeader.Version != LitVersion {
+		return fmt.Errorf("unknown .lit file version (%d)", litHeader.Version)
+	}
+
+	size := len(lit) - binary.Size(litHeader)
+	if size != int(header.Lumps[LumpLighting].FileLen)*3 {
+		return fmt.Errorf(".lit file has %d bytes of lighting, but the map needs %d", size, header.Lumps[LumpLighting].FileLen*3)
+	}
+
+	return nil
+}
+
+// litSamples returns the lighting in a .lit file that ValidateLit accepted
+func litSamples(lit []byte) []byte {
+	return lit[binary.Size(LitHeader{}):]
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/vkngwrapper/quake/filesystem"
+)
+
+// Loader loads models from the game's filesystem
+type Loader struct {
+	out   io.Writer
+	files *filesystem.FileSystem
+}
+
+// NewLoader creates a loader that reports problems that don't stop a model from loading to out
+func NewLoader(out io.Writer, files *filesystem.FileSystem) *Loader {
+	return &Loader{out: out, files: files}
+}
+
+// LoadBrushModel loads a bsp file, along with the .lit file beside it if there is one.  See
+// ParseBrushModel.
+func (l *Loader) LoadBrushModel(name string) ([]*QModel, error) {
+	data, pathId := l.files.LoadFile(name)
+	if data == nil {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+
+	header, err := ReadHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	litName := LitPath(name)
+	lit, litPathId := l.files.LoadFile(litName)
+	if lit != nil && litPathId < pathId {
+		// A .lit file is only used if it's from the map's own game directory or a later one
+		_, _ = fmt.Fprintf(l.out, "ignored %s from a gamedir with lower priority\n", litName)
+		lit = nil
+	}
+	if lit != nil {
+		err = ValidateLit(lit, header)
+		if err != nil {
+			_, _ = fmt.Fprintf(l.out, "%s: %s, ignoring\n", litName, err)
+			lit = nil
+		}
+	}
+
+	models, err := ParseBrushModel(name, data, lit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mod := range models {
+		mod.PathId = pathId
+	}
+
+	return models, nil
+}
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"fmt"
+	stdmath "math"
+
+	"github.com/vkngwrapper/math"
+)
+
+// MaxSurfaceExtents is the largest a lit surface can be along either texture axis, in world units
+// at the default lightmap scale.  GLQuake allowed 512 and WinQuake 256, so old maps that were
+// only tested in FitzQuake and later can go over those.
+const MaxSurfaceExtents int = 2000
+
+// Size of the textures that surface lightmaps are packed into
+const (
+	LightMapBlockWidth  int = 1024
+	LightMapBlockHeight int = 1024
+)
+
+// surfaceVertex returns the position of a surface's i'th vertex, following its edges in order
+func (m *QModel) surfaceVertex(surf *MSurface, i int) *math.Vec3[float32] {
+	edge := m.SurfEdges[surf.FirstEdge+i]
+	if edge >= 0 {
+		return &m.Vertices[m.Edges[edge].V[0]].Position
+	}
+
+	return &m.Vertices[m.Edges[-edge].V[1]].Position
+}
+
+// CalcSurfaceExtents sets the texture coordinates where a surface's lightmap starts and how far it
+// extends, and returns an error if it's too large to light.  Sky and water surfaces can be any
+// size, so the surface's flags have to be set first.  Decoupled lightmaps already have their
+// extents from the lump that describes them.
+func (m *QModel) CalcSurfaceExtents(surf *MSurface) error {
+	texInfo := surf.TexInfo
+	lightMapScale := 1 << surf.LightMapShift
+	checkExtents := surf.Flags&(SurfDrawSky|SurfDrawTurb) == 0
+
+	if !surf.DecoupledLightMap {
+		mins := [2]float64{stdmath.MaxFloat64, stdmath.MaxFloat64}
+		maxs := [2]float64{-stdmath.MaxFloat64, -stdmath.MaxFloat64}
+		for i := 0; i < surf.NumEdges; i++ {
+			v := m.surfaceVertex(surf, i)
+			for j := 0; j < 2; j++ {
+				// This is sensitive to precision, and some maps have seams if it isn't done in doubles
+				val := float64(v.X)*float64(texInfo.Vecs[j][0]) +
+					float64(v.Y)*float64(texInfo.Vecs[j][1]) +
+					float64(v.Z)*float64(texInfo.Vecs[j][2]) +
+					float64(texInfo.Vecs[j][3])
+				mins[j] = min(mins[j], val)
+				maxs[j] = max(maxs[j], val)
+			}
+		}
+
+		for i := 0; i < 2; i++ {
+			bmin := int(stdmath.Floor(mins[i] / float64(lightMapScale)))
+			bmax := int(stdmath.Ceil(maxs[i] / float64(lightMapScale)))
+			extent := (bmax - bmin) * lightMapScale
+
+			surf.TextureMins[i] = int16(bmin * lightMapScale)
+			surf.Extents[i] = int16(min(extent, stdmath.MaxInt16))
+			if checkExtents && extent > MaxSurfaceExtents<<surf.LightMapShift>>DefaultLightMapShift {
+				return fmt.Errorf("bad surface extents %d on %s", extent, texInfo.Texture.Name)
+			}
+		}
+
+		return nil
+	}
+
+	for _, extent := range surf.Extents {
+		if checkExtents && int(extent) > MaxSurfaceExtents>>DefaultLightMapShift {
+			return fmt.Errorf("bad surface extents %d on %s", extent, texInfo.Texture.Name)
+		}
+	}
+
+	return nil
+}
+
+// LightMapSize returns the width and height of a surface's lightmap, in samples
+func (surf *MSurface) LightMapSize() (int, int) {
+	return int(surf.Extents[0])>>surf.LightMapShift + 1, int(surf.Extents[1])>>surf.LightMapShift + 1
+}
+
+// LightMapAllocator packs surface lightmaps into blocks of LightMapBlockWidth by
+// LightMapBlockHeight samples.  Only the newest block is filled, keeping track of how much of
+// each of its columns is used.
+type LightMapAllocator struct {
+	NumBlocks int
+	allocated [LightMapBlockWidth]int
+}
+
+// Alloc finds room for a w by h lightmap, starting a new block when the current one is full, and
+// returns the block and the position of the lightmap in it
+func (a *LightMapAllocator) Alloc(w, h int) (block, x, y int, err error) {
+	if w <= 0 || h <= 0 || w > LightMapBlockWidth || h > LightMapBlockHeight {
+		return 0, 0, 0, fmt.Errorf("%dx%d lightmap doesn't fit in a %dx%d block", w, h, LightMapBlockWidth, LightMapBlockHeight)
+	}
+
+	if a.NumBlocks > 0 {
+		x, y, ok := a.fit(w, h)
+		if ok {
+			return a.NumBlocks - 1, x, y, nil
+		}
+	}
+
+	a.NumBlocks++
+	a.allocated = [LightMapBlockWidth]int{}
+	x, y, _ = a.fit(w, h)
+	return a.NumBlocks - 1, x, y, nil
+}
+
+// fit places a w by h lightmap as high up in the current block as it can go
+func (a *LightMapAllocator) fit(w, h int) (int, int, bool) {
+	bestX, bestY := 0, LightMapBlockHeight
+	for x := 0; x <= LightMapBlockWidth-w; x++ {
+		y := 0
+		j := 0
+		for ; j < w; j++ {
+			if a.allocated[x+j] >= bestY {
+				break
+			}
+			y = max(y, a.allocated[x+j])
+		}
+
+		if j == w {
+			bestX, bestY = x, y
+		}
+	}
+
+	if bestY+h > LightMapBlockHeight {
+		return 0, 0, false
+	}
+
+	for j := 0; j < w; j++ {
+		a.allocated[bestX+j] = bestY + h
+	}
+	return bestX, bestY, true
+}
+
+// BuildSurfaces allocates lightmaps for the model's surfaces and builds the polygons they're drawn
+// with.  Tiled surfaces, like sky and unlit water, don't get a lightmap.  It's called once on the
+// world model after it's loaded, which also covers the surfaces of its submodels.
+func (m *QModel) BuildSurfaces(lightMaps *LightMapAllocator) error {
+	for i := range m.Surfaces {
+		surf := &m.Surfaces[i]
+		if surf.Flags&SurfDrawTiled == 0 {
+			block, s, t, err := lightMaps.Alloc(surf.LightMapSize())
+			if err != nil {
+				return fmt.Errorf("surface %d: %w", i, err)
+			}
+
+			surf.LightMapTextureNum = block
+			surf.LightS = s
+			surf.LightT = t
+		}
+
+		m.BuildSurfaceDisplayList(surf)
+	}
+
+	return nil
+}
+
+// BuildSurfaceDisplayList adds a polygon to the surface with its world space vertices, texture
+// coordinates, and coordinates in the lightmap block at LightS and LightT.  BuildSurfaces
+// allocates the lightmap before calling it.
+func (m *QModel) BuildSurfaceDisplayList(surf *MSurface) {
+	texInfo := surf.TexInfo
+	lightMapScale := float32(int(1) << surf.LightMapShift)
+
+	poly := &GLPoly{
+		Next:     surf.Polys,
+		NumVerts: surf.NumEdges,
+		Verts:    make([][VertexSize]float32, surf.NumEdges),
+	}
+	surf.Polys = poly
+
+	for i := range poly.Verts {
+		v := m.surfaceVertex(surf, i)
+		vert := &poly.Verts[i]
+
+		s := v.X*texInfo.Vecs[0][0] + v.Y*texInfo.Vecs[0][1] + v.Z*texInfo.Vecs[0][2] + texInfo.Vecs[0][3]
+		t := v.X*texInfo.Vecs[1][0] + v.Y*texInfo.Vecs[1][1] + v.Z*texInfo.Vecs[1][2] + texInfo.Vecs[1][3]
+
+		vert[0] = v.X
+		vert[1] = v.Y
+		vert[2] = v.Z
+		vert[3] = s / float32(texInfo.Texture.Width)
+		vert[4] = t / float32(texInfo.Texture.Height)
+
+		// Lightmap coordinates, in samples from the start of the lightmap
+		var lightS, lightT float32
+		if surf.DecoupledLightMap {
+			vecs := &surf.LightMapVecs
+			lightS = v.X*vecs[0][0] + v.Y*vecs[0][1] + v.Z*vecs[0][2] + vecs[0][3]
+			lightT = v.X*vecs[1][0] + v.Y*vecs[1][1] + v.Z*vecs[1][2] + vecs[1][3]
+		} else {
+			lightS = (s - float32(surf.TextureMins[0])) / lightMapScale
+			lightT = (t - float32(surf.TextureMins[1])) / lightMapScale
+		}
+
+		// Sample from the middle of the texels
+		vert[5] = (lightS + float32(surf.LightS) + 0.5) / float32(LightMapBlockWidth)
+		vert[6] = (lightT + float32(surf.LightT) + 0.5) / float32(LightMapBlockHeight)
+	}
+}
@@ -0,0 +1,120 @@
+package model
+
+import "testing"
+
+func TestSurfaceExtentsLimit(t *testing.T) {
+	tests := []struct {
+		texture string
+		ok      bool
+	}{
+		{texture: "wall", ok: false},
+		{texture: "sky1", ok: true},
+		{texture: "*water0", ok: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.texture, func(t *testing.T) {
+			// Scale the texture so that the 64 unit wall is 4096 texels across
+			lumps := testLumps()
+			lumps[LumpTextures] = testTextures(test.texture)
+			lumps[LumpTexInfo] = []dTexInfo{{Vecs: [2][4]float32{{0, 64, 0, 0}, {0, 0, -1, 0}}, MipTex: 0}}
+
+			models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+			if !test.ok {
+				if err == nil {
+					t.Error("oversized face was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if extents := models[0].Surfaces[0].Extents; extents != [2]int16{4096, 64} {
+				t.Errorf("got extents %v", extents)
+			}
+		})
+	}
+}
+
+func TestBuildSurfaces(t *testing.T) {
+	models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, testLumps()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := models[0]
+
+	lightMaps := &LightMapAllocator{}
+	err = world.BuildSurfaces(lightMaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lightMaps.NumBlocks != 1 {
+		t.Errorf("expected 1 lightmap block, got %d", lightMaps.NumBlocks)
+	}
+
+	surf := &world.Surfaces[0]
+	if w, h := surf.LightMapSize(); w != 5 || h != 5 {
+		t.Errorf("expected a 5x5 lightmap, got %dx%d", w, h)
+	}
+	if surf.Polys == nil || surf.Polys.NumVerts != 4 {
+		t.Fatalf("expected a polygon with 4 vertices, got %+v", surf.Polys)
+	}
+
+	// The second vertex is at (0, 64, 0), the far corner of the texture and lightmap from the
+	// texture's origin at (0, 0, 64)
+	want := [VertexSize]float32{0, 64, 0, 4, 0, 4.5 / 1024, 4.5 / 1024}
+	if got := surf.Polys.Verts[1]; got != want {
+		t.Errorf("got vertex %v, want %v", got, want)
+	}
+}
+
+func TestBuildSurfacesSkipsSkyLightMaps(t *testing.T) {
+	lumps := testLumps()
+	lumps[LumpTextures] = testTextures("sky1")
+	models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lightMaps := &LightMapAllocator{}
+	err = models[0].BuildSurfaces(lightMaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lightMaps.NumBlocks != 0 {
+		t.Errorf("sky surface allocated %d lightmap blocks", lightMaps.NumBlocks)
+	}
+	if models[0].Surfaces[0].Polys == nil {
+		t.Error("sky surface has no polygon")
+	}
+}
+
+func TestLightMapAllocator(t *testing.T) {
+	tests := []struct {
+		w, h        int
+		block, x, y int
+	}{
+		{w: LightMapBlockWidth, h: 1000, block: 0, x: 0, y: 0},
+		{w: 10, h: 10, block: 0, x: 0, y: 1000},
+		// Nothing this wide fits under the first two
+		{w: LightMapBlockWidth, h: 20, block: 1, x: 0, y: 0},
+		{w: 5, h: 5, block: 1, x: 0, y: 20},
+		{w: 5, h: 5, block: 1, x: 5, y: 20},
+	}
+
+	a := &LightMapAllocator{}
+	for i, test := range tests {
+		block, x, y, err := a.Alloc(test.w, test.h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block != test.block || x != test.x || y != test.y {
+			t.Errorf("lightmap %d: got block %d at %d,%d, want block %d at %d,%d", i, block, x, y, test.block, test.x, test.y)
+		}
+	}
+
+	_, _, _, err := a.Alloc(LightMapBlockWidth+1, 1)
+	if err == nil {
+		t.Error("lightmap wider than a block was allocated")
+	}
+}
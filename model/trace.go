@@ -0,0 +1,201 @@
+package model
+
+import "github.com/vkngwrapper/math"
+
+// DistEpsilon is how far in front of a plane a trace stops, so that the end point isn't in the solid
+const DistEpsilon float32 = 0.03125
+
+// Trace is the result of moving a point through a hull
+type Trace struct {
+	AllSolid   bool    // the whole trace was in a solid
+	StartSolid bool    // the trace started in a solid
+	InOpen     bool    // the trace passed through empty space
+	InWater    bool    // the trace passed through a liquid
+	Fraction   float32 // how much of the move was completed, 1 if nothing was hit
+	EndPos     math.Vec3[float32]
+	Plane      MPlane // the plane that was hit, facing the start
+	Contents   int    // contents of the last leaf the trace passed through
+}
+
+// NewBoxHull creates a hull shaped like a box, so that entities that aren't brush models can be
+// collided with in the same way as those that are
+func NewBoxHull(mins, maxs math.Vec3[float32]) *Hull {
+	hull := &Hull{
+		ClipNodes:     make([]MClipNode, 6),
+		Planes:        make([]MPlane, 6),
+		FirstClipNode: 0,
+		LastClipNode:  5,
+	}
+
+	dists := [6]float32{maxs.X, mins.X, maxs.Y, mins.Y, maxs.Z, mins.Z}
+	for i := range hull.ClipNodes {
+		side := i & 1
+		hull.ClipNodes[i].PlaneNum = i
+		hull.ClipNodes[i].Children[side] = ContentsEmpty
+		if i != 5 {
+			hull.ClipNodes[i].Children[side^1] = i + 1
+		} else {
+			hull.ClipNodes[i].Children[side^1] = ContentsSolid
+		}
+
+		plane := &hull.Planes[i]
+		plane.Type = byte(i >> 1)
+		switch i >> 1 {
+		case 0:
+			plane.Normal.X = 1
+		case 1:
+			plane.Normal.Y = 1
+		case 2:
+			plane.Normal.Z = 1
+		}
+		plane.Dist = dists[i]
+	}
+
+	return hull
+}
+
+// HullForSize returns the hull that a box of the given size collides with, and the offset to add to
+// the box's origin to get the origin of the hull's box
+func (m *QModel) HullForSize(mins, maxs math.Vec3[float32]) (*Hull, math.Vec3[float32]) {
+	var hull *Hull
+	size := maxs.X - mins.X
+	if size < 3 {
+		hull = &m.Hulls[0]
+	} else if size <= 32 {
+		hull = &m.Hulls[1]
+	} else {
+		hull = &m.Hulls[2]
+	}
+
+	var offset math.Vec3[float32]
+	offset.SetSubtractVec3(&hull.ClipMins, &mins)
+	return hull, offset
+}
+
+// Trace moves a box from start to end through the model, stopping where it hits a solid
+func (m *QModel) Trace(start, mins, maxs, end math.Vec3[float32]) Trace {
+	hull, offset := m.HullForSize(mins, maxs)
+
+	var startLocal, endLocal math.Vec3[float32]
+	startLocal.SetSubtractVec3(&start, &offset)
+	endLocal.SetSubtractVec3(&end, &offset)
+
+	trace := hull.Trace(startLocal, endLocal)
+	trace.EndPos.AddVec3(&offset)
+	return trace
+}
+
+// Trace moves a point from start to end through the hull, stopping where it hits a solid
+func (h *Hull) Trace(start, end math.Vec3[float32]) Trace {
+	trace := Trace{
+		AllSolid: true,
+		Fraction: 1,
+		EndPos:   end,
+	}
+	num := h.FirstClipNode
+	if num >= 0 && len(h.ClipNodes) == 0 {
+		// Hulls without clipnodes are empty everywhere
+		num = ContentsEmpty
+	}
+	h.recursiveHullCheck(num, 0, 1, start, end, &trace)
+
+	return trace
+}
+
+func lerpVec3(p1, p2 *math.Vec3[float32], frac float32) math.Vec3[float32] {
+	return math.Vec3[float32]{
+		X: p1.X + frac*(p2.X-p1.X),
+		Y: p1.Y + frac*(p2.Y-p1.Y),
+		Z: p1.Z + frac*(p2.Z-p1.Z),
+	}
+}
+
+// recursiveHullCheck traces the part of the move from p1 to p2, which are at fractions p1f and
+// p2f of the whole move, through the subtree at num.  It returns false once the trace has hit
+// something.
+func (h *Hull) recursiveHullCheck(num int, p1f, p2f float32, p1, p2 math.Vec3[float32], trace *Trace) bool {
+	// Check for empty
+	if num < 0 {
+		trace.Contents = num
+		if num != ContentsSolid {
+			trace.AllSolid = false
+			if num == ContentsEmpty {
+				trace.InOpen = true
+			} else {
+				trace.InWater = true
+			}
+		} else {
+			trace.StartSolid = true
+		}
+		return true
+	}
+
+	node := &h.ClipNodes[num]
+	plane := &h.Planes[node.PlaneNum]
+	t1 := planeDist(plane, &p1)
+	t2 := planeDist(plane, &p2)
+
+	if t1 >= 0 && t2 >= 0 {
+		return h.recursiveHullCheck(node.Children[0], p1f, p2f, p1, p2, trace)
+	}
+	if t1 < 0 && t2 < 0 {
+		return h.recursiveHullCheck(node.Children[1], p1f, p2f, p1, p2, trace)
+	}
+
+	// Put the crosspoint DistEpsilon units on the near side
+	var frac float32
+	if t1 < 0 {
+		frac = (t1 + DistEpsilon) / (t1 - t2)
+	} else {
+		frac = (t1 - DistEpsilon) / (t1 - t2)
+	}
+	frac = min(max(frac, 0), 1)
+
+	midf := p1f + (p2f-p1f)*frac
+	mid := lerpVec3(&p1, &p2, frac)
+
+	side := 0
+	if t1 < 0 {
+		side = 1
+	}
+
+	// Move up to the node
+	if !h.recursiveHullCheck(node.Children[side], p1f, midf, p1, mid, trace) {
+		return false
+	}
+
+	if h.PointContents(node.Children[side^1], mid) != ContentsSolid {
+		// Go past the node
+		return h.recursiveHullCheck(node.Children[side^1], midf, p2f, mid, p2, trace)
+	}
+
+	if trace.AllSolid {
+		// Never got out of the solid area
+		return false
+	}
+
+	// The other side of the node is solid, this is the impact point
+	if side == 0 {
+		trace.Plane.Normal = plane.Normal
+		trace.Plane.Dist = plane.Dist
+	} else {
+		trace.Plane.Normal.SetScale(&plane.Normal, -1)
+		trace.Plane.Dist = -plane.Dist
+	}
+
+	for h.PointContents(h.FirstClipNode, mid) == ContentsSolid {
+		// Shouldn't really happen, but does occasionally
+		frac -= 0.1
+		if frac < 0 {
+			trace.Fraction = midf
+			trace.EndPos = mid
+			return false
+		}
+		midf = p1f + (p2f-p1f)*frac
+		mid = lerpVec3(&p1, &p2, frac)
+	}
+
+	trace.Fraction = midf
+	trace.EndPos = mid
+	return false
+}
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/vkngwrapper/math"
+)
+
+var (
+	pointMins  = math.Vec3[float32]{}
+	playerMins = math.Vec3[float32]{X: -16, Y: -16, Z: -24}
+	playerMaxs = math.Vec3[float32]{X: 16, Y: 16, Z: 32}
+)
+
+func loadTraceModel(t *testing.T, lumps map[int]any) *QModel {
+	t.Helper()
+
+	models, err := ParseBrushModel("maps/test.bsp", buildBSP(t, BSPVersion29, lumps), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return models[0]
+}
+
+func TestTrace(t *testing.T) {
+	// The world is solid behind the x = 0 plane and empty in front of it
+	world := loadTraceModel(t, testLumps())
+
+	tests := []struct {
+		name       string
+		start, end math.Vec3[float32]
+		want       Trace
+	}{
+		{
+			name:  "open",
+			start: math.Vec3[float32]{X: 32, Y: 32, Z: 32},
+			end:   math.Vec3[float32]{X: 48, Y: 32, Z: 32},
+			want: Trace{
+				InOpen:   true,
+				Fraction: 1,
+				EndPos:   math.Vec3[float32]{X: 48, Y: 32, Z: 32},
+				Contents: ContentsEmpty,
+			},
+		},
+		{
+			name:  "hits the wall",
+			start: math.Vec3[float32]{X: 32, Y: 32, Z: 32},
+			end:   math.Vec3[float32]{X: -32, Y: 32, Z: 32},
+			want: Trace{
+				InOpen:   true,
+				Fraction: (32 - DistEpsilon) / 64,
+				EndPos:   math.Vec3[float32]{X: DistEpsilon, Y: 32, Z: 32},
+				Plane:    MPlane{Normal: math.Vec3[float32]{X: 1}},
+				Contents: ContentsEmpty,
+			},
+		},
+		{
+			name:  "all solid",
+			start: math.Vec3[float32]{X: -32, Y: 32, Z: 32},
+			end:   math.Vec3[float32]{X: -48, Y: 32, Z: 32},
+			want: Trace{
+				AllSolid:   true,
+				StartSolid: true,
+				Fraction:   1,
+				EndPos:     math.Vec3[float32]{X: -48, Y: 32, Z: 32},
+				Contents:   ContentsSolid,
+			},
+		},
+		{
+			name:  "starts solid",
+			start: math.Vec3[float32]{X: -32, Y: 32, Z: 32},
+			end:   math.Vec3[float32]{X: 32, Y: 32, Z: 32},
+			want: Trace{
+				StartSolid: true,
+				InOpen:     true,
+				Fraction:   1,
+				EndPos:     math.Vec3[float32]{X: 32, Y: 32, Z: 32},
+				Contents:   ContentsEmpty,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := world.Trace(test.start, pointMins, pointMins, test.end)
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTraceFlipsPlane(t *testing.T) {
+	// Hull 1 is empty behind the x = 0 plane and solid in front of it, so a trace from behind
+	// hits the back of the plane
+	lumps := testLumps()
+	lumps[LumpClipNodes] = []dClipNode29{
+		{PlaneNum: 0, Children: [2]int16{int16(ContentsSolid), int16(ContentsEmpty)}},
+	}
+	world := loadTraceModel(t, lumps)
+
+	got := world.Trace(math.Vec3[float32]{X: -32}, playerMins, playerMaxs, math.Vec3[float32]{X: 32})
+	want := Trace{
+		InOpen:   true,
+		Fraction: (32 - DistEpsilon) / 64,
+		EndPos:   math.Vec3[float32]{X: -DistEpsilon},
+		Plane:    MPlane{Normal: math.Vec3[float32]{X: -1}},
+		Contents: ContentsEmpty,
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestTraceBacksOffFromSolid(t *testing.T) {
+	// Hull 1 is split at x = 0.  In front of it, the world is solid behind x = 0.01, and behind it
+	// the world is solid behind y = 0.  A trace that hits y = 0 just behind x = 0 stops a little
+	// in front of x = 0, where the front of the tree puts it in the solid, so it has to back off.
+	lumps := testLumps()
+	lumps[LumpPlanes] = []dPlane{
+		{Normal: [3]float32{1, 0, 0}, Dist: 0, Type: int32(PlaneX)},
+		{Normal: [3]float32{1, 0, 0}, Dist: 0.01, Type: int32(PlaneX)},
+		{Normal: [3]float32{0, 1, 0}, Dist: 0, Type: int32(PlaneY)},
+	}
+	lumps[LumpClipNodes] = []dClipNode29{
+		{PlaneNum: 0, Children: [2]int16{1, 2}},
+		{PlaneNum: 1, Children: [2]int16{int16(ContentsEmpty), int16(ContentsSolid)}},
+		{PlaneNum: 2, Children: [2]int16{int16(ContentsEmpty), int16(ContentsSolid)}},
+	}
+	world := loadTraceModel(t, lumps)
+
+	start := math.Vec3[float32]{X: 1, Y: 10}
+	end := math.Vec3[float32]{X: -0.1, Y: -1}
+	got := world.Trace(start, playerMins, playerMaxs, end)
+
+	if got.Fraction >= 1 || got.Plane.Normal != (math.Vec3[float32]{Y: 1}) {
+		t.Fatalf("expected to hit the y = 0 plane, got %+v", got)
+	}
+	// Without backing off, the trace would have stopped DistEpsilon in front of y = 0
+	if got.EndPos.Y <= DistEpsilon {
+		t.Errorf("trace didn't back off: %+v", got)
+	}
+	if contents := world.HullPointContents(1, got.EndPos); contents == ContentsSolid {
+		t.Errorf("trace ended in the solid at %v", got.EndPos)
+	}
+}
+
+func TestTraceWithoutClipNodes(t *testing.T) {
+	lumps := testLumps()
+	delete(lumps, LumpClipNodes)
+	world := loadTraceModel(t, lumps)
+
+	end := math.Vec3[float32]{X: -32}
+	got := world.Trace(math.Vec3[float32]{X: 32}, playerMins, playerMaxs, end)
+	if got.Fraction != 1 || got.StartSolid || got.EndPos != end {
+		t.Errorf("expected hull without clipnodes to be empty, got %+v", got)
+	}
+}
+
+func TestBoxHullTrace(t *testing.T) {
+	hull := NewBoxHull(math.Vec3[float32]{X: -10, Y: -10, Z: -10}, math.Vec3[float32]{X: 10, Y: 10, Z: 10})
+
+	tests := []struct {
+		name       string
+		start, end math.Vec3[float32]
+		want       Trace
+	}{
+		{
+			name:  "hits the mins side",
+			start: math.Vec3[float32]{X: -50},
+			end:   math.Vec3[float32]{X: 50},
+			want: Trace{
+				InOpen:   true,
+				Fraction: (40 - DistEpsilon) / 100,
+				EndPos:   math.Vec3[float32]{X: -10 - DistEpsilon},
+				Plane:    MPlane{Normal: math.Vec3[float32]{X: -1}, Dist: 10},
+				Contents: ContentsEmpty,
+			},
+		},
+		{
+			name:  "hits the maxs side",
+			start: math.Vec3[float32]{Z: 50},
+			end:   math.Vec3[float32]{Z: 0},
+			want: Trace{
+				InOpen:   true,
+				Fraction: (40 - DistEpsilon) / 50,
+				EndPos:   math.Vec3[float32]{Z: 10 + DistEpsilon},
+				Plane:    MPlane{Normal: math.Vec3[float32]{Z: 1}, Dist: 10},
+				Contents: ContentsEmpty,
+			},
+		},
+		{
+			name:  "misses",
+			start: math.Vec3[float32]{X: -50, Y: 20},
+			end:   math.Vec3[float32]{X: 50, Y: 20},
+			want: Trace{
+				InOpen:   true,
+				Fraction: 1,
+				EndPos:   math.Vec3[float32]{X: 50, Y: 20},
+				Contents: ContentsEmpty,
+			},
+		},
+		{
+			name:  "inside",
+			start: math.Vec3[float32]{},
+			end:   math.Vec3[float32]{X: 5},
+			want: Trace{
+				AllSolid:   true,
+				StartSolid: true,
+				Fraction:   1,
+				EndPos:     math.Vec3[float32]{X: 5},
+				Contents:   ContentsSolid,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := hull.Trace(test.start, test.end); got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
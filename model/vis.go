@@ -0,0 +1,145 @@
+package model
+
+import "github.com/vkngwrapper/math"
+
+// VisRowSize returns the number of bytes in the model's PVS, which has a bit for every leaf with
+// visibility data.  Bit 0 is leaf 1, since leaf 0 is the solid leaf that can't see anything.
+func (m *QModel) VisRowSize() int {
+	return (m.NumLeafs + 7) >> 3
+}
+
+// DecompressVis expands a leaf's compressed PVS.  Runs of zero bytes are compressed to a zero
+// followed by the length of the run.  VisWarn is set if the data doesn't fit the model's leafs.
+func (m *QModel) DecompressVis(in []byte) []byte {
+	if in == nil {
+		// No vis info, so make everything visible
+		return m.NoVisPVS()
+	}
+
+	out := make([]byte, 0, m.VisRowSize())
+	for len(out) < cap(out) {
+		if len(in) == 0 {
+			// The data ran out, leave the rest of the row invisible
+			m.VisWarn = true
+			break
+		}
+
+		if in[0] != 0 {
+			out = append(out, in[0])
+			in = in[1:]
+			continue
+		}
+
+		if len(in) < 2 {
+			m.VisWarn = true
+			break
+		}
+
+		run := int(in[1])
+		in = in[2:]
+		if run > cap(out)-len(out) {
+			m.VisWarn = true
+			run = cap(out) - len(out)
+		}
+		out = append(out, make([]byte, run)...)
+	}
+
+	return out[:cap(out)]
+}
+
+// NoVisPVS returns a PVS in which every leaf is visible
+func (m *QModel) NoVisPVS() []byte {
+	pvs := make([]byte, m.VisRowSize())
+	for i := range pvs {
+		pvs[i] = 0xff
+	}
+
+	return pvs
+}
+
+// LeafPVS returns the PVS of one of the model's leafs
+func (m *QModel) LeafPVS(leaf *MLeaf) []byte {
+	if leaf == &m.Leafs[0] {
+		return m.NoVisPVS()
+	}
+
+	return m.DecompressVis(leaf.CompressedVis)
+}
+
+// PVSContains reports whether a PVS has the leaf with the given index in it
+func PVSContains(pvs []byte, leaf int) bool {
+	bit := leaf - 1
+	if bit < 0 || bit>>3 >= len(pvs) {
+		return false
+	}
+
+	return pvs[bit>>3]&(1<<(bit&7)) != 0
+}
+
+// VisibleLeafs returns the indices of the leafs that can be seen from a leaf
+func (m *QModel) VisibleLeafs(leaf *MLeaf) []int {
+	pvs := m.LeafPVS(leaf)
+
+	var leafs []int
+	for i := 1; i <= m.NumLeafs; i++ {
+		if PVSContains(pvs, i) {
+			leafs = append(leafs, i)
+		}
+	}
+
+	return leafs
+}
+
+// FatPVS returns the combined PVS of every leaf within 8 units of a point, so that servers don't
+// leave out entities that a client at a slightly different position could see
+func (m *QModel) FatPVS(org math.Vec3[float32]) []byte {
+	fatPVS := make([]byte, m.VisRowSize())
+	m.addToFatPVS(fatPVS, org, m.Hulls[0].FirstClipNode)
+
+	return fatPVS
+}
+
+func (m *QModel) addToFatPVS(fatPVS []byte, org math.Vec3[float32], node int) {
+	for node >= 0 {
+		plane := m.Nodes[node].Plane
+		d := org.DotProduct(&plane.Normal) - plane.Dist
+		if d > 8 {
+			node = m.Nodes[node].Children[0]
+		} else if d < -8 {
+			node = m.Nodes[node].Children[1]
+		} else {
+			// Go down both
+			m.addToFatPVS(fatPVS, org, m.Nodes[node].Children[0])
+			node = m.Nodes[node].Children[1]
+		}
+	}
+
+	leaf := &m.Leafs[-1-node]
+	if leaf.Contents == ContentsSolid {
+		return
+	}
+
+	pvs := m.LeafPVS(leaf)
+	for i := range fatPVS {
+		fatPVS[i] |= pvs[i]
+	}
+}
+
+// MarkVisibleSurfaces sets the VisFrame of the surfaces in every leaf of a PVS to visFrame,
+// skipping leafs that cull returns true for
+func (m *QModel) MarkVisibleSurfaces(pvs []byte, visFrame int, cull func(leaf *MLeaf) bool) {
+	for i := 1; i <= m.NumLeafs; i++ {
+		if !PVSContains(pvs, i) {
+			continue
+		}
+
+		leaf := &m.Leafs[i]
+		if cull != nil && cull(leaf) {
+			continue
+		}
+
+		for _, surf := range m.MarkSurfaces[leaf.FirstMarkSurface : leaf.FirstMarkSurface+leaf.NumMarkSurfaces] {
+			m.Surfaces[surf].VisFrame = visFrame
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/vkngwrapper/math"
+)
+
+// visTestModel returns a model with 20 visible leafs, so that each PVS row is 3 bytes.  Its only
+// node splits the world at x = 0, with leaf 1 in front and leaf 2 behind.
+func visTestModel(vis ...[]byte) *QModel {
+	m := &QModel{
+		NumLeafs: 20,
+		Leafs:    make([]MLeaf, 21),
+		Planes:   []MPlane{{Normal: math.Vec3[float32]{X: 1}}},
+	}
+	m.Leafs[0].Contents = ContentsSolid
+	for i := 1; i < len(m.Leafs); i++ {
+		m.Leafs[i].Contents = ContentsEmpty
+	}
+	for i, compressed := range vis {
+		m.Leafs[i+1].CompressedVis = compressed
+	}
+
+	m.Nodes = []MNode{{Plane: &m.Planes[0], Children: [2]int{-2, -3}}}
+	return m
+}
+
+func TestDecompressVis(t *testing.T) {
+	tests := []struct {
+		name       string
+		compressed []byte
+		want       []byte
+		warn       bool
+	}{
+		{
+			name:       "uncompressed",
+			compressed: []byte{0x01, 0x02, 0x03},
+			want:       []byte{0x01, 0x02, 0x03},
+		},
+		{
+			name:       "zero run",
+			compressed: []byte{0x01, 0x00, 0x01, 0x80},
+			want:       []byte{0x01, 0x00, 0x80},
+		},
+		{
+			name:       "zero run to the end of the row",
+			compressed: []byte{0x00, 0x03},
+			want:       []byte{0x00, 0x00, 0x00},
+		},
+		{
+			name:       "run overflows the row",
+			compressed: []byte{0x01, 0x00, 0x05, 0xff},
+			want:       []byte{0x01, 0x00, 0x00},
+			warn:       true,
+		},
+		{
+			name:       "data runs out",
+			compressed: []byte{0x01},
+			want:       []byte{0x01, 0x00, 0x00},
+			warn:       true,
+		},
+		{
+			name:       "run without a length",
+			compressed: []byte{0x01, 0x00},
+			want:       []byte{0x01, 0x00, 0x00},
+			warn:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := visTestModel()
+			got := m.DecompressVis(test.compressed)
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("got %x, want %x", got, test.want)
+			}
+			if m.VisWarn != test.warn {
+				t.Errorf("got VisWarn %t, want %t", m.VisWarn, test.warn)
+			}
+		})
+	}
+}
+
+func TestLeafPVSWithoutVis(t *testing.T) {
+	m := visTestModel(nil)
+
+	want := []byte{0xff, 0xff, 0xff}
+	if got := m.LeafPVS(&m.Leafs[1]); !bytes.Equal(got, want) {
+		t.Errorf("leaf without vis data got %x, want %x", got, want)
+	}
+	if got := m.LeafPVS(&m.Leafs[0]); !bytes.Equal(got, want) {
+		t.Errorf("solid leaf got %x, want %x", got, want)
+	}
+	if visible := m.VisibleLeafs(&m.Leafs[1]); len(visible) != m.NumLeafs {
+		t.Errorf("expected all %d leafs to be visible, got %v", m.NumLeafs, visible)
+	}
+}
+
+func TestVisibleLeafs(t *testing.T) {
+	// Leaf 1 sees itself and leaf 20, past a run of zeros
+	m := visTestModel([]byte{0x01, 0x00, 0x01, 0x08})
+
+	want := []int{1, 20}
+	if got := m.VisibleLeafs(&m.Leafs[1]); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFatPVS(t *testing.T) {
+	// Leaf 1 sees leafs 1 and 3, leaf 2 sees leafs 2 and 12
+	m := visTestModel([]byte{0x05, 0x00, 0x02}, []byte{0x02, 0x08, 0x00})
+
+	tests := []struct {
+		name string
+		org  math.Vec3[float32]
+		want []byte
+	}{
+		{name: "in front", org: math.Vec3[float32]{X: 100}, want: []byte{0x05, 0x00, 0x00}},
+		{name: "behind", org: math.Vec3[float32]{X: -100}, want: []byte{0x02, 0x08, 0x00}},
+		{name: "near the split", org: math.Vec3[float32]{X: 4}, want: []byte{0x07, 0x08, 0x00}},
+		{name: "just behind the split", org: math.Vec3[float32]{X: -8}, want: []byte{0x07, 0x08, 0x00}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := m.FatPVS(test.org); !bytes.Equal(got, test.want) {
+				t.Errorf("got %x, want %x", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package parse
+
+import "strings"
+
+type OverflowBehavior int
+
+const (
+	OverflowFail OverflowBehavior = iota
+	OverflowTruncate
+)
+
+const MaxTokenSize int = 4096
+
+func Token(data []rune) string {
+	return TokenWithOverflowBehavior(data, OverflowFail)
+}
+
+func TokenWithOverflowBehavior(data []rune, overflow OverflowBehavior) string {
+	token, _ := NextToken(data, overflow)
+	return token
+}
+
+// NextToken parses the first token in data and returns it along with the data following it.
+// Whitespace, // line comments and /* block comments */ before the token are skipped.  A
+// token is a quoted string, one of the single character tokens { } ( ) ' : or a run of
+// non-whitespace.  remaining is nil if data held no token, or if the token was longer than
+// MaxTokenSize and overflow is OverflowFail.  Any input is safe to parse.
+func NextToken(data []rune, overflow OverflowBehavior) (token string, remaining []rune) {
+	parsedToken := make([]rune, 0, 64)
+	appendRune := func(r rune) bool {
+		if len(parsedToken) < MaxTokenSize {
+			parsedToken = append(parsedToken, r)
+		} else if overflow == OverflowFail {
+			return false
+		}
+		return true
+	}
+
+	// peek returns the rune at index, or 0 past the end of data
+	peek := func(index int) rune {
+		if index < len(data) {
+			return data[index]
+		}
+		return 0
+	}
+
+	dataIndex := 0
+	for {
+		for dataIndex < len(data) && data[dataIndex] <= ' ' {
+			dataIndex++
+		}
+
+		if dataIndex >= len(data) {
+			return "", nil
+		}
+
+		if data[dataIndex] == '/' && peek(dataIndex+1) == '/' {
+			// Single line comment
+			for dataIndex < len(data) && data[dataIndex] != '\n' {
+				dataIndex++
+			}
+			continue
+		}
+
+		if data[dataIndex] == '/' && peek(dataIndex+1) == '*' {
+			// Block comment, which runs to the end of data if it isn't closed
+			dataIndex += 2
+			for dataIndex < len(data) && (data[dataIndex] != '*' || peek(dataIndex+1) != '/') {
+				dataIndex++
+			}
+			dataIndex = min(dataIndex+2, len(data))
+			continue
+		}
+
+		break
+	}
+
+	r := data[dataIndex]
+
+	// Handle quoted string, an unterminated quote runs to the end of data
+	if r == '"' {
+		dataIndex++
+		for dataIndex < len(data) {
+			r = data[dataIndex]
+			dataIndex++
+
+			if r == '"' {
+				break
+			}
+
+			if !appendRune(r) {
+				return "", nil
+			}
+		}
+
+		return string(parsedToken), data[dataIndex:]
+	}
+
+	// Parse single characters
+	if isSingleCharToken(r) || r == ':' {
+		return string(r), data[dataIndex+1:]
+	}
+
+	for dataIndex < len(data) && data[dataIndex] > ' ' {
+		if !appendRune(data[dataIndex]) {
+			return "", nil
+		}
+		dataIndex++
+
+		if isSingleCharToken(peek(dataIndex)) {
+			break
+		}
+	}
+
+	return string(parsedToken), data[dataIndex:]
+}
+
+// isSingleCharToken reports whether r is always a token of its own.  ':' is also a token when
+// it starts one, but can appear inside words so that ip:port addresses parse as one token.
+func isSingleCharToken(r rune) bool {
+	return r == '{' || r == '}' || r == '(' || r == ')' || r == '\''
+}
+
+// CommandEnd returns the index of the newline or semicolon that ends the first command in
+// text, or len(text) if the command runs to the end.  Semicolons inside quotes or comments
+// don't end the command.
+func CommandEnd(text []rune) int {
+	quotes := 0
+	comment := false
+	var textIndex int
+	for textIndex = 0; textIndex < len(text); textIndex++ {
+		if text[textIndex] == '"' {
+			quotes++
+		}
+		if text[textIndex] == '/' && len(text)-1 > textIndex && text[textIndex+1] == '/' {
+			comment = true
+		}
+		if quotes%2 == 0 && !comment && text[textIndex] == ';' {
+			// Encountered a semicolon not inside a quote and not inside a comment
+			break
+		}
+		if text[textIndex] == '\n' {
+			break
+		}
+	}
+
+	return textIndex
+}
+
+// Command splits a single command into at most maxArgs arguments, the same way the command
+// executor does.  argString is the unparsed text following the command name.
+func Command(line []rune, maxArgs int) (args []string, argString string) {
+	text := line
+	for {
+		// Skip whitespace, a linebreak ends the command
+		for len(text) > 0 && text[0] <= ' ' && text[0] != '\n' {
+			text = text[1:]
+		}
+
+		if len(text) == 0 || text[0] == '\n' {
+			break
+		}
+
+		if len(args) == 1 {
+			argString = strings.TrimRight(string(text), "\r\n")
+		}
+
+		token, remaining := NextToken(text, OverflowFail)
+		if remaining == nil {
+			// Only a comment or a token that was too long was left
+			break
+		}
+		text = remaining
+
+		if len(args) < maxArgs {
+			args = append(args, token)
+		}
+	}
+
+	return args, argString
+}
@@ -0,0 +1,89 @@
+package parse
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var fuzzSeeds = []string{
+	"",
+	"\"unterminated",
+	"echo \"unterminated; quote",
+	"/* unterminated",
+	"bind x /* unterminated */ /*",
+	"//",
+	"connect 127.0.0.1:26000",
+	"connect [::1]:26000; wait",
+	":port",
+	"{ \"classname\" \"worldspawn\" }",
+	"alias zoom \"fov 90; wait\" // comment",
+	strings.Repeat("a", MaxTokenSize+1),
+	"\"" + strings.Repeat("a", MaxTokenSize+1) + "\"",
+}
+
+func FuzzNextToken(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed, true)
+	}
+
+	f.Fuzz(func(t *testing.T, input string, truncate bool) {
+		overflow := OverflowFail
+		if truncate {
+			overflow = OverflowTruncate
+		}
+
+		data := []rune(input)
+		for {
+			token, remaining := NextToken(data, overflow)
+			if remaining == nil {
+				return
+			}
+
+			if len(remaining) >= len(data) {
+				t.Fatalf("no progress parsing %q: %q remains", string(data), string(remaining))
+			}
+			if !slices.Equal(data[len(data)-len(remaining):], remaining) {
+				t.Fatalf("%q is not a suffix of %q", string(remaining), string(data))
+			}
+			if utf8.RuneCountInString(token) > MaxTokenSize {
+				t.Fatalf("token of %d runes is longer than %d", utf8.RuneCountInString(token), MaxTokenSize)
+			}
+
+			data = remaining
+		}
+	})
+}
+
+func FuzzCommand(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed, 80)
+	}
+	f.Add("a b c d", 2)
+	f.Add("a b", 0)
+
+	f.Fuzz(func(t *testing.T, input string, maxArgs int) {
+		if maxArgs < 0 || maxArgs > 1024 {
+			return
+		}
+
+		text := []rune(input)
+		for len(text) > 0 {
+			end := CommandEnd(text)
+			if end < 0 || end > len(text) {
+				t.Fatalf("command end %d is outside of %d runes", end, len(text))
+			}
+
+			args, _ := Command(text[:end], maxArgs)
+			if len(args) > maxArgs {
+				t.Fatalf("got %d args, more than the %d allowed", len(args), maxArgs)
+			}
+
+			if end < len(text) {
+				end++
+			}
+			text = text[end:]
+		}
+	})
+}
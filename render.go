@@ -1,6 +1,9 @@
 package main
 
-import "github.com/vkngwrapper/math"
+import (
+	"github.com/vkngwrapper/math"
+	"github.com/vkngwrapper/quake/model"
+)
 
 type LightCache struct {
 	SurfaceIndex int32 // <0 black surface, ==0 no cache, >0 1+index of surface
@@ -28,7 +31,7 @@ type Entity struct {
 	Origin     math.Vec3[float32]
 	MsgAngles  [2]math.Vec3[float32] // last two updates - 0 is newest
 	Angles     math.Vec3[float32]
-	Model      *QModel         // nil = no model
+	Model      *model.QModel   // nil = no model
 	EFrag      *EntityFragment // linked list of entity fragments
 	Frame      int32
 	SyncBase   float32 // for client-side animations
@@ -40,7 +43,7 @@ type Entity struct {
 	DynamicLightFrame int32
 	DynamicLightBits  int32
 
-	TopNode *MNode // for bmodels, first world node that splits bmodel, or NULL if not split
+	TopNode *model.MNode // for bmodels, first world node that splits bmodel, or NULL if not split
 
 	EFlags       byte // Mostly a mirror of netstate, but handles tag inheritance
 	Alpha        byte
@@ -69,6 +72,17 @@ type Entity struct {
 	ContentsCacheOrigin math.Vec3[float32]
 }
 
+// PointContents returns the contents of the world at the entity's origin, only looking them up
+// again when the entity has moved
+func (e *Entity) PointContents(world *model.QModel) int32 {
+	if e.ContentsCache == 0 || e.ContentsCacheOrigin != e.Origin {
+		e.ContentsCache = int32(world.PointContents(e.Origin))
+		e.ContentsCacheOrigin = e.Origin
+	}
+
+	return e.ContentsCache
+}
+
 type RenderDefinition struct {
 	VideoRect                                 VideoRect // subwindow in video for refresh
 	AliasVideoRect                            VideoRect // scaled alias version
@@ -95,6 +109,9 @@ type RenderDefinition struct {
 	FovX, FovY float32
 
 	AmbientLight int
+
+	WorldModel *model.QModel            // nil when no map is loaded
+	LightMaps  *model.LightMapAllocator // lightmap blocks used by the world's surfaces
 }
 
 var RenderData = &RenderDefinition{}
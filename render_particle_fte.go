@@ -1,6 +1,9 @@
 package main
 
-import "github.com/vkngwrapper/math"
+import (
+	"github.com/vkngwrapper/math"
+	"github.com/vkngwrapper/quake/model"
+)
 
 type Particle struct {
 	Next *Particle
@@ -33,7 +36,7 @@ type SkyTris struct {
 	Area     float32
 	NextTime float64
 	PType    int
-	Face     *MSurface
+	Face     *model.MSurface
 }
 
 type SkyTriBlock struct {
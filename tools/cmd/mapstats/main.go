@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/vkngwrapper/quake/filesystem"
+	"github.com/vkngwrapper/quake/model"
+)
+
+// limits are the most of each thing that an engine can handle.  0 means there is no limit.
+type limits struct {
+	name    string
+	formats []model.Format
+
+	visLeafs     int
+	nodes        int
+	clipNodes    int
+	markSurfaces int
+	faces        int
+	vertices     int
+	texInfo      int
+	models       int
+	entities     int
+	entityLump   int
+}
+
+var engineLimits = []limits{
+	{
+		name:         "vanilla",
+		formats:      []model.Format{model.FormatBSP29},
+		visLeafs:     8192,
+		nodes:        32767,
+		clipNodes:    32767,
+		markSurfaces: 32767,
+		faces:        32767,
+		vertices:     65535,
+		texInfo:      4096,
+		models:       256,
+		entities:     600,
+		entityLump:   65536,
+	},
+	{
+		name:         "FitzQuake",
+		formats:      []model.Format{model.FormatBSP29},
+		visLeafs:     32767,
+		nodes:        32767,
+		clipNodes:    65520,
+		markSurfaces: 65535,
+		faces:        65535,
+		vertices:     65535,
+		texInfo:      32767,
+		models:       2048,
+		entities:     8192,
+	},
+	{
+		name:     "BSP2",
+		formats:  []model.Format{model.FormatBSP29, model.Format2PSB, model.FormatBSP2},
+		models:   4096,
+		entities: 32000,
+	},
+}
+
+func main() {
+	args := os.Args
+	if len(args) < 3 {
+		log.Fatalln("Usage: mapstats [base dir] [map, e.g. maps/e1m1.bsp] [game dir (optional)]")
+	}
+
+	files := filesystem.New(filesystem.Options{BaseDir: args[1]})
+	err := files.AddGameDirectory(filesystem.GameName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(args) > 3 {
+		err = files.AddGameDirectory(args[3])
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	mapName := args[2]
+	data, _ := files.LoadFile(mapName)
+	if data == nil {
+		log.Fatalf("Could not find map '%s'\n", mapName)
+	}
+	header, err := model.ReadHeader(data)
+	if err != nil {
+		log.Fatalf("Could not read map '%s': %s\n", mapName, err)
+	}
+
+	models, err := model.NewLoader(os.Stderr, files).LoadBrushModel(mapName)
+	if err != nil {
+		log.Fatalf("Could not load map '%s': %s\n", mapName, err)
+	}
+	world := models[0]
+
+	entities, err := model.ParseEntities(world.Entities)
+	if err != nil {
+		log.Fatalf("Could not parse the entities of map '%s': %s\n", mapName, err)
+	}
+
+	fmt.Printf("%s: %s format\n", mapName, world.Format())
+
+	printLumps(world, header)
+	printLimits(world, entities)
+	printTextures(world)
+	printVisibility(world)
+	printLighting(world, files, mapName, header)
+	printEntities(entities)
+}
+
+func printLumps(world *model.QModel, header *model.Header) {
+	counts := [model.NumLumps]int{
+		model.LumpPlanes:       len(world.Planes),
+		model.LumpTextures:     len(world.Textures) - 2,
+		model.LumpVertices:     len(world.Vertices),
+		model.LumpNodes:        len(world.Nodes),
+		model.LumpTexInfo:      len(world.TexInfo),
+		model.LumpFaces:        len(world.Surfaces),
+		model.LumpClipNodes:    len(world.ClipNodes),
+		model.LumpLeafs:        len(world.Leafs),
+		model.LumpMarkSurfaces: len(world.MarkSurfaces),
+		model.LumpEdges:        len(world.Edges),
+		model.LumpSurfEdges:    len(world.SurfEdges),
+		model.LumpModels:       len(world.Submodels),
+	}
+
+	fmt.Println("\nLumps:")
+	for i, lump := range header.Lumps {
+		if i == model.LumpEntities || i == model.LumpVisibility || i == model.LumpLighting {
+			fmt.Printf("  %-14s %10d bytes\n", model.LumpNames[i], lump.FileLen)
+			continue
+		}
+		fmt.Printf("  %-14s %10d bytes %8d records\n", model.LumpNames[i], lump.FileLen, counts[i])
+	}
+
+	for _, name := range world.BSPX.Names() {
+		fmt.Printf("  BSPX %-9s %10d bytes\n", name, len(world.BSPX.Lump(name)))
+	}
+}
+
+func printLimits(world *model.QModel, entities []*model.Entity) {
+	counts := []struct {
+		name  string
+		count int
+		limit func(l *limits) int
+	}{
+		{"visleafs", world.NumLeafs, func(l *limits) int { return l.visLeafs }},
+		{"nodes", len(world.Nodes), func(l *limits) int { return l.nodes }},
+		{"clipnodes", len(world.ClipNodes), func(l *limits) int { return l.clipNodes }},
+		{"marksurfaces", len(world.MarkSurfaces), func(l *limits) int { return l.markSurfaces }},
+		{"faces", len(world.Surfaces), func(l *limits) int { return l.faces }},
+		{"vertices", len(world.Vertices), func(l *limits) int { return l.vertices }},
+		{"texinfo", len(world.TexInfo), func(l *limits) int { return l.texInfo }},
+		{"models", len(world.Submodels), func(l *limits) int { return l.models }},
+		{"entities", len(entities), func(l *limits) int { return l.entities }},
+		{"entity lump", len(world.Entities), func(l *limits) int { return l.entityLump }},
+	}
+
+	fmt.Println("\nLimits:")
+	for i := range engineLimits {
+		engine := &engineLimits[i]
+		if !slices.Contains(engine.formats, world.Format()) {
+			fmt.Printf("  %-10s can't load %s maps\n", engine.name, world.Format())
+			continue
+		}
+
+		exceeded := false
+		for _, c := range counts {
+			limit := c.limit(engine)
+			if limit > 0 && c.count > limit {
+				fmt.Printf("  %-10s %s: %d exceeds the limit of %d\n", engine.name, c.name, c.count, limit)
+				exceeded = true
+			}
+		}
+		if !exceeded {
+			fmt.Printf("  %-10s ok\n", engine.name)
+		}
+	}
+}
+
+func printTextures(world *model.QModel) {
+	textureCount := len(world.Textures) - 2
+	var missingSlots []int
+	for i, texture := range world.Textures[:textureCount] {
+		if texture == nil {
+			missingSlots = append(missingSlots, i)
+		}
+	}
+
+	missingTexInfo := 0
+	for i := range world.TexInfo {
+		if world.TexInfo[i].Flags&model.TexMissing != 0 {
+			missingTexInfo++
+		}
+	}
+
+	fmt.Printf("\nTextures: %d\n", textureCount)
+	if len(missingSlots) > 0 {
+		fmt.Printf("  missing from the bsp: %v\n", missingSlots)
+	}
+	if missingTexInfo > 0 {
+		fmt.Printf("  %d texinfo use missing textures\n", missingTexInfo)
+	}
+}
+
+func printVisibility(world *model.QModel) {
+	fmt.Println("\nVisibility:")
+	if len(world.VisData) == 0 {
+		fmt.Println("  no visibility data, the map leaked or wasn't vised")
+		return
+	}
+
+	noVis := 0
+	for i := 1; i <= world.NumLeafs; i++ {
+		if world.Leafs[i].CompressedVis == nil {
+			noVis++
+		}
+	}
+
+	fmt.Printf("  %d bytes for %d leafs\n", len(world.VisData), world.NumLeafs)
+	if noVis > 0 {
+		fmt.Printf("  %d leafs have no visibility data\n", noVis)
+	}
+}
+
+func printLighting(world *model.QModel, files *filesystem.FileSystem, mapName string, header *model.Header) {
+	litFaces := 0
+	lightMaps := 0
+	var styleCounts [256]int
+	for i := range world.Surfaces {
+		surf := &world.Surfaces[i]
+		if surf.Samples == nil {
+			continue
+		}
+
+		litFaces++
+		for _, style := range surf.Styles {
+			if style == 255 {
+				break
+			}
+			lightMaps++
+			styleCounts[style]++
+		}
+	}
+
+	source := "nowhere, it's grayscale"
+	lit, _ := files.LoadFile(model.LitPath(mapName))
+	if lit != nil && model.ValidateLit(lit, header) == nil {
+		source = model.LitPath(mapName)
+	} else if world.BSPX.Lump(model.BSPXRGBLighting) != nil {
+		source = "BSPX " + model.BSPXRGBLighting
+	}
+
+	fmt.Println("\nLighting:")
+	fmt.Printf("  %d bytes, color from %s\n", len(world.LightData), source)
+	fmt.Printf("  %d of %d faces lit, with %d lightmaps\n", litFaces, len(world.Surfaces), lightMaps)
+	for style, count := range styleCounts {
+		if count > 0 {
+			fmt.Printf("  style %3d: %d lightmaps\n", style, count)
+		}
+	}
+}
+
+func printEntities(entities []*model.Entity) {
+	counts := make(map[string]int)
+	for _, entity := range entities {
+		counts[entity.ClassName()]++
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Printf("\nEntities: %d\n", len(entities))
+	for _, name := range names {
+		fmt.Printf("  %-32s %d\n", name, counts[name])
+	}
+}
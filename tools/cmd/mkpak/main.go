@@ -9,13 +9,9 @@ import (
 	"os"
 	"path"
 	"strings"
-)
 
-type PackFile struct {
-	Name    [56]rune
-	FilePos int32
-	FileLen int32
-}
+	"github.com/vkngwrapper/quake/filesystem"
+)
 
 func writeBytes(w io.Writer, bytes []byte) error {
 	index := 0
@@ -31,15 +27,11 @@ func writeBytes(w io.Writer, bytes []byte) error {
 }
 
 func writeHeader(w io.Writer, dirOffset int32, dirSize int32) error {
-	err := writeBytes(w, []byte{'P', 'A', 'C', 'K'})
-	if err != nil {
-		return err
-	}
-	err = binary.Write(w, binary.LittleEndian, dirOffset)
-	if err != nil {
-		return err
-	}
-	return binary.Write(w, binary.LittleEndian, dirSize)
+	return binary.Write(w, binary.LittleEndian, filesystem.PackHeader{
+		ID:        filesystem.PackID,
+		DirOffset: dirOffset,
+		DirSize:   dirSize,
+	})
 }
 
 func main() {
@@ -101,8 +93,8 @@ func main() {
 		log.Fatalf("Error while reading toc_file '%s': %s\n", tocFilePath, tocScanner.Err())
 	}
 
-	dirOffset := int32(12)
-	dirSize := int32(64 * len(tocFiles))
+	dirOffset := int32(binary.Size(filesystem.PackHeader{}))
+	dirSize := int32(filesystem.PakFileSize * len(tocFiles))
 	fileOffset := dirOffset + dirSize
 
 	outFile, err := os.Create(outputPakPath)
@@ -133,21 +125,18 @@ func main() {
 			log.Fatalf("Error while opening input file '%s': %s", tocEntryPath, err)
 		}
 
-		var name [56]rune
-		tocEntryRunes := []rune(tocEntry)
-		copy(name[:], tocEntryRunes)
-		if len(tocEntryRunes) < 56 {
-			name[len(tocEntryRunes)] = 0
+		if len(tocEntry) >= filesystem.PakNameLength {
+			log.Fatalf("Input file name '%s' is too long for a pak", tocEntry)
 		}
 
 		fileLen := int32(len(inputFileBytes))
-		packFile := PackFile{
-			Name:    name,
+		packFile := filesystem.PackDirEntry{
 			FilePos: fileOffset,
 			FileLen: fileLen,
 		}
+		copy(packFile.Name[:], tocEntry)
 
-		_, err = outFile.Seek(int64(dirOffset)+64*int64(fileIndex), 0)
+		_, err = outFile.Seek(int64(dirOffset)+int64(filesystem.PakFileSize)*int64(fileIndex), 0)
 		if err != nil {
 			log.Fatalf("Error while seeking within output file '%s': %s", outputPakPath, err)
 		}